@@ -1,15 +1,17 @@
 package main
 
 import (
-	"crypto/sha256"
+	"flag"
 	"fmt"
+	"httpfromtcp/internal/compress"
+	"httpfromtcp/internal/fileserver"
 	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/proxy"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
 	"httpfromtcp/internal/server"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
 	"strings"
@@ -18,6 +20,32 @@ import (
 
 const port = 8888 //42069
 
+var (
+	upstreams  = flag.String("upstreams", "", "comma separated upstream urls; when set every request is load balanced across them")
+	strategy   = flag.String("strategy", "round-robin", "load balancing strategy: round-robin, least-connections or consistent-hash")
+	healthPath = flag.String("health-path", "", "path used for active upstream health checks")
+
+	forwardProxy = flag.Bool("forward-proxy", false, "act as a forward proxy for absolute-form and CONNECT requests")
+	allow        = flag.String("allow", "", "comma separated destinations the forward proxy may reach")
+	deny         = flag.String("deny", "", "comma separated destinations the forward proxy refuses")
+
+	compression    = flag.Bool("compress", true, "gzip or deflate text responses for clients that accept it")
+	decompress     = flag.Bool("decompress", true, "decode gzip or deflate request bodies before handling them")
+	maxDecodedSize = flag.Int64("max-decoded-size", 10<<20, "largest decoded request body in bytes; 0 means no limit")
+
+	serverName = flag.String("server-name", "", "value of the Server field added to responses; empty leaves it out")
+
+	assetsDir = flag.String("assets", "./assets", "directory served under /assets/")
+	listing   = flag.Bool("listing", false, "render listings for asset directories without an index.html")
+)
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
 var template string = `<html>
   <head>
     <title>%s</title>
@@ -52,76 +80,41 @@ func writeErr(w io.Writer, err error) {
 }
 
 func main() {
+	flag.Parse()
+
+	httpbin, err := proxy.NewReverseProxy("https://httpbin.org")
+	if err != nil {
+		log.Fatalf("Error creating proxy: %v", err)
+	}
+	httpbin.StripPrefix = "/httpbin"
+
+	// the server still starts without the assets directory; /video reports why
+	assets, assetsErr := fileserver.NewFileServer(*assetsDir)
+	if assetsErr == nil {
+		assets.StripPrefix = "/assets"
+		assets.Listing = *listing
+		defer assets.Close()
+	}
 
 	handler := func(w io.Writer, r *request.Request) {
 		rw := response.NewWriter(w)
 
-		// chunked encoding
-		prefix := "/httpbin/"
-		if strings.HasPrefix(r.RequestLine.RequestTarget, prefix) {
-
-			remote := fmt.Sprintf("https://httpbin.org/%s",
-				strings.TrimPrefix(r.RequestLine.RequestTarget, prefix))
-			fmt.Printf("REMOTE: %s\n", remote)
-			res, err := http.Get(remote)
-			if err != nil {
-				writeErr(w, err)
-				return
-			}
-			buf := make([]byte, 1024)
-
-			rw.WriteStatusLine(response.OK)
-			h := headers.NewHeaders()
-			h.Set(map[string]string{
-				"content-type":      "text/plain",
-				"Transfer-Encoding": "chunked",
-				"Trailer":           "X-Content-Sha256, X-Content-Length",
-			})
-			rw.WriteHeaders(h)
-
-			cnt := 0
-			body := []byte{}
-
-			for {
-				n, err := res.Body.Read(buf)
-				cnt += n
-				if err != nil {
-					if err == io.EOF {
-						rw.WriteChunkedBodyDone(true)
-						break
-					}
-					fmt.Printf("Read error: %v\n", err)
-					break
-				}
-				body = append(body, buf[:n]...)
-				rw.WriteChunkedBody(buf[:n])
-			}
-
-			trailers := headers.NewHeaders()
-			sum := sha256.Sum256(body)
-			trailers.Set(map[string]string{
-				"X-Content-Length": fmt.Sprintf("%d", cnt),
-				"X-Content-Sha256": fmt.Sprintf("%x", sum),
-			})
-			rw.WriteTrailers(trailers)
-
+		if strings.HasPrefix(r.RequestLine.RequestTarget, "/httpbin/") {
+			httpbin.Handle(w, r)
 			return
 		}
 
 		if strings.HasPrefix(r.RequestLine.RequestTarget, "/video") {
-			body, err := os.ReadFile("./assets/vim.mp4")
-			if err != nil {
-				writeErr(w, err)
+			if assets == nil {
+				writeErr(w, assetsErr)
 				return
 			}
+			assets.ServeFile(w, r, "vim.mp4")
+			return
+		}
 
-			rw.WriteStatusLine(response.OK)
-			h := headers.NewHeaders()
-			h.SetDefault(len(body), map[string]string{
-				"content-type": "video/mp4",
-			})
-			rw.WriteHeaders(h)
-			rw.WriteBody(body)
+		if strings.HasPrefix(r.RequestLine.RequestTarget, "/assets/") && assets != nil {
+			assets.Handle(w, r)
 			return
 		}
 
@@ -170,11 +163,58 @@ func main() {
 			return
 		}
 	}
+
+	if *upstreams != "" {
+		lb, err := proxy.NewLoadBalancer(splitList(*upstreams))
+		if err != nil {
+			log.Fatalf("Error creating load balancer: %v", err)
+		}
+
+		lb.Strategy, err = proxy.ParseStrategy(*strategy)
+		if err != nil {
+			log.Fatalf("Error creating load balancer: %v", err)
+		}
+
+		if *healthPath != "" {
+			lb.HealthCheckPath = *healthPath
+			lb.StartHealthChecks()
+			defer lb.StopHealthChecks()
+		}
+
+		handler = lb.Handle
+	}
+
+	if *compression {
+		handler = compress.NewCompressor().Wrap(handler)
+	}
+
+	if *decompress {
+		d := compress.NewDecompressor()
+		d.MaxSize = *maxDecodedSize
+		handler = d.Wrap(handler)
+	}
+
+	if *forwardProxy {
+		fp := proxy.NewForwardProxy()
+		fp.Allow = splitList(*allow)
+		fp.Deny = splitList(*deny)
+
+		next := handler
+		handler = func(w io.Writer, r *request.Request) {
+			if proxy.IsForwardRequest(r) {
+				fp.Handle(w, r)
+				return
+			}
+			next(w, r)
+		}
+	}
+
 	server, err := server.Serv(port, handler)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
 	defer server.Close()
+	server.SetName(*serverName)
 	log.Println("Server started on port", port)
 
 	sigChan := make(chan os.Signal, 1)
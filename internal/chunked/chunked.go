@@ -0,0 +1,117 @@
+package chunked
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const lineSeparator = "\r\n"
+const extensionSeparator = ";"
+const maxSizeLineLength = 1024
+
+type decodeState int
+
+var (
+	sizeState    decodeState = 0
+	dataState    decodeState = 1
+	dataEndState decodeState = 2
+	doneState    decodeState = 3
+)
+
+var (
+	ErrWrongSizeFormat = errors.New("wrong chunk size format")
+	ErrSizeLineTooLong = errors.New("chunk size line too long")
+	ErrWrongChunkEnd   = errors.New("wrong chunk end")
+)
+
+// Decoder decodes a chunked transfer coding body piece by piece.
+// Trailers after the last chunk are left to the caller.
+type Decoder struct {
+	state decodeState
+	left  int64
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{
+		state: sizeState,
+	}
+}
+
+func (d *Decoder) Reset() {
+	d.state = sizeState
+	d.left = 0
+}
+
+// Parse consumes a single chunk size line, a piece of chunk data or a chunk
+// terminator from data. chunk aliases data; done is true once the last
+// chunk was read.
+func (d *Decoder) Parse(data []byte) (n int, chunk []byte, done bool, err error) {
+	switch d.state {
+	case sizeState:
+		idx := bytes.Index(data, []byte(lineSeparator))
+		if idx == -1 {
+			if len(data) > maxSizeLineLength {
+				return 0, nil, false, ErrSizeLineTooLong
+			}
+			return 0, nil, false, nil
+		}
+
+		size, err := parseSize(string(data[:idx]))
+		if err != nil {
+			return 0, nil, false, err
+		}
+
+		if size == 0 {
+			d.state = doneState
+			return idx + 2, nil, true, nil
+		}
+
+		d.left = size
+		d.state = dataState
+		return idx + 2, nil, false, nil
+
+	case dataState:
+		if len(data) == 0 {
+			return 0, nil, false, nil
+		}
+
+		l := int(min(int64(len(data)), d.left))
+		d.left -= int64(l)
+		if d.left == 0 {
+			d.state = dataEndState
+		}
+		return l, data[:l], false, nil
+
+	case dataEndState:
+		if len(data) < 2 {
+			return 0, nil, false, nil
+		}
+
+		if !bytes.HasPrefix(data, []byte(lineSeparator)) {
+			return 0, nil, false, ErrWrongChunkEnd
+		}
+
+		d.state = sizeState
+		return 2, nil, false, nil
+	}
+
+	return 0, nil, true, nil
+}
+
+func parseSize(line string) (int64, error) {
+	line, _, _ = strings.Cut(line, extensionSeparator)
+	line = strings.TrimRight(line, " \t")
+
+	if line == "" || len(line) > 16 {
+		return 0, ErrWrongSizeFormat
+	}
+
+	size, err := strconv.ParseUint(line, 16, 63)
+	if err != nil {
+		return 0, ErrWrongSizeFormat
+	}
+
+	return int64(size), nil
+}
@@ -0,0 +1,99 @@
+package chunked
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAll(t *testing.T, data string) (string, int, error) {
+	t.Helper()
+
+	d := NewDecoder()
+	body := []byte{}
+	read := 0
+	for {
+		n, chunk, done, err := d.Parse([]byte(data[read:]))
+		if err != nil {
+			return "", read, err
+		}
+		body = append(body, chunk...)
+		read += n
+		if done {
+			return string(body), read, nil
+		}
+		if n == 0 {
+			return string(body), read, nil
+		}
+	}
+}
+
+func TestDecoderParse(t *testing.T) {
+	tests := []struct {
+		input string
+		body  string
+		n     int
+		err   error
+		notes string
+	}{
+		{
+			input: "5\r\nhello\r\n7\r\n, world\r\n0\r\n\r\n",
+			body:  "hello, world",
+			n:     25,
+			notes: "Two chunks",
+		},
+		{
+			input: "A;name=value\r\n0123456789\r\n0\r\n",
+			body:  "0123456789",
+			n:     29,
+			notes: "Chunk extension",
+		},
+		{
+			input: "5\r\nhel",
+			body:  "hel",
+			n:     6,
+			notes: "Partial chunk",
+		},
+		{
+			input: "z\r\n",
+			err:   ErrWrongSizeFormat,
+			notes: "Wrong size",
+		},
+		{
+			input: "-1\r\n",
+			err:   ErrWrongSizeFormat,
+			notes: "Negative size",
+		},
+		{
+			input: "0x5\r\nhello\r\n",
+			err:   ErrWrongSizeFormat,
+			notes: "Size with prefix",
+		},
+		{
+			input: "3\r\nhelloworld",
+			err:   ErrWrongChunkEnd,
+			notes: "Chunk longer than size",
+		},
+	}
+
+	for _, tt := range tests {
+		body, n, err := decodeAll(t, tt.input)
+		assert.Equal(t, tt.err, err, tt.notes+": error")
+		if tt.err == nil {
+			assert.Equal(t, tt.body, body, tt.notes+": body")
+			assert.Equal(t, tt.n, n, tt.notes+": n")
+		}
+	}
+}
+
+func TestDecoderSizeLineTooLong(t *testing.T) {
+	d := NewDecoder()
+	data := make([]byte, maxSizeLineLength+1)
+	for i := range data {
+		data[i] = '1'
+	}
+
+	_, _, _, err := d.Parse(data)
+	require.Equal(t, ErrSizeLineTooLong, err)
+}
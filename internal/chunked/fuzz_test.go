@@ -0,0 +1,73 @@
+package chunked
+
+import (
+	"fmt"
+	"testing"
+)
+
+func decode(data []byte) ([]byte, int, bool, error) {
+	d := NewDecoder()
+	body := []byte{}
+	read := 0
+	for {
+		n, chunk, done, err := d.Parse(data[read:])
+		if err != nil {
+			return nil, read, false, err
+		}
+		body = append(body, chunk...)
+		read += n
+		if done || n == 0 {
+			return body, read, done, nil
+		}
+	}
+}
+
+func FuzzDecoderParse(f *testing.F) {
+	seeds := []string{
+		"5\r\nhello\r\n7\r\n, world\r\n0\r\n\r\n",
+		"6;ext=1\r\nworld!\r\n0\r\n",
+		"0\r\n\r\n",
+		"10000000000000001\r\nA\r\n0\r\n\r\n",
+		"ffffffffffffffff\r\n",
+		"2;\nxx\r\n10\r\n1f\r\nAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA0\r\n\r\n",
+		"5 \r\nhello\r\n0\r\n",
+		"0x5\r\nhello\r\n0\r\n",
+		"-5\r\nhello\r\n0\r\n",
+		"3\r\nhelloworld\r\n0\r\n",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		body, n, done, err := decode(data)
+		if err != nil {
+			return
+		}
+
+		if n > len(data) {
+			t.Fatalf("consumed %d of %d bytes", n, len(data))
+		}
+
+		if len(body) > len(data) {
+			t.Fatalf("decoded %d bytes from %d bytes", len(body), len(data))
+		}
+
+		if !done {
+			return
+		}
+
+		encoded := []byte("0\r\n")
+		if len(body) > 0 {
+			encoded = fmt.Appendf(nil, "%x\r\n%s\r\n0\r\n", len(body), body)
+		}
+
+		again, m, done, err := decode(encoded)
+		if err != nil || !done {
+			t.Fatalf("re-decode of %q: done %v, error %v", encoded, done, err)
+		}
+		if m != len(encoded) || string(again) != string(body) {
+			t.Fatalf("round trip of %q gave %q", body, again)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 30 * time.Second
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported scheme")
+	ErrMissingHost       = errors.New("missing host")
+)
+
+// DefaultClient is used by Get.
+var DefaultClient = NewClient()
+
+type Client struct {
+	// Timeout limits dialing, sending the request and reading the response
+	// head; zero means no limit.
+	Timeout time.Duration
+	// ReadTimeout limits each read of the response body, so streamed bodies
+	// may take as long as they keep arriving; zero means no limit.
+	ReadTimeout time.Duration
+	// Pool keeps connections alive between requests; nil disables reuse.
+	Pool *Pool
+}
+
+// target is where a request goes: addr is dialed unless dialAddr is set,
+// host is sent in the Host header and path is the origin-form request
+// target.
+type target struct {
+	scheme   string
+	host     string
+	addr     string
+	dialAddr string
+	path     string
+}
+
+func NewClient() *Client {
+	return &Client{
+		Timeout:     defaultTimeout,
+		ReadTimeout: defaultTimeout,
+		Pool:        NewPool(),
+	}
+}
+
+// NewRequest builds a request for an absolute http or https url.
+func NewRequest(method string, rawURL string, body []byte) (*request.Request, error) {
+	if _, err := parseURL(rawURL); err != nil {
+		return nil, err
+	}
+
+	return &request.Request{
+		RequestLine: request.RequestLine{
+			HttpVersion:   "1.1",
+			RequestTarget: rawURL,
+			Method:        method,
+		},
+		Headers:  headers.NewHeaders(),
+		Body:     body,
+		Trailers: headers.NewHeaders(),
+	}, nil
+}
+
+func Get(rawURL string) (*response.Response, error) {
+	req, err := NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return DefaultClient.Do(req)
+}
+
+// Do sends req and reads the whole response. The request target is either an
+// absolute url or an origin-form path with the Host header naming the server.
+func (c *Client) Do(req *request.Request) (*response.Response, error) {
+	var res *response.Response
+
+	err := c.Stream(req, func(ev response.Event) error {
+		if ev.Type == response.EventStatusLine {
+			res = &response.Response{
+				Headers:  headers.NewHeaders(),
+				Trailers: headers.NewHeaders(),
+			}
+		}
+		res.Apply(ev)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Stream sends req and passes the response events to fn as soon as they are
+// parsed. Event data is only valid during the call to fn; an error returned
+// by fn aborts the exchange.
+func (c *Client) Stream(req *request.Request, fn func(response.Event) error) error {
+	return c.StreamVia("", req, fn)
+}
+
+// StreamVia is Stream connecting to addr instead of the address in req's
+// target, such as one a proxy already resolved and checked. Host and TLS
+// server name still come from the target.
+func (c *Client) StreamVia(addr string, req *request.Request, fn func(response.Event) error) error {
+	t, err := requestTarget(req)
+	if err != nil {
+		return err
+	}
+	if addr != "" {
+		t.dialAddr = addr
+	}
+
+	out := req.Clone()
+	out.RequestLine.RequestTarget = t.path
+	if _, ok := out.Headers.Get("host"); !ok {
+		out.Headers["host"] = t.host
+	}
+	if c.Pool == nil {
+		out.Headers["connection"] = "close"
+	}
+
+	key := t.scheme + "://" + t.addr
+	if t.dialAddr != "" {
+		key += "@" + t.dialAddr
+	}
+	if c.Pool != nil {
+		if pc := c.Pool.get(key); pc != nil {
+			started, err := c.roundTrip(pc, out, fn)
+			// the server may have dropped the connection right after the
+			// health check; idempotent requests get one more try
+			if err == nil || started || !request.IsIdempotent(out.RequestLine.Method) {
+				return err
+			}
+		}
+	}
+
+	nc, err := c.dial(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.roundTrip(newConn(nc, key), out, fn)
+	return err
+}
+
+// roundTrip sends req over pc and either returns pc to the pool or closes it.
+// started reports whether any event reached fn.
+func (c *Client) roundTrip(pc *conn, req *request.Request, fn func(response.Event) error) (started bool, err error) {
+	pc.readTimeout = 0
+	if c.Timeout > 0 {
+		pc.SetDeadline(time.Now().Add(c.Timeout))
+	}
+
+	if err := req.Write(pc); err != nil {
+		pc.Close()
+		return false, err
+	}
+
+	statusLine := response.StatusLine{}
+	h := headers.NewHeaders()
+
+	err = pc.reader.Stream(req.RequestLine.Method, func(ev response.Event) error {
+		started = true
+		switch ev.Type {
+		case response.EventStatusLine:
+			statusLine = ev.StatusLine
+		case response.EventHeader:
+			h.Add(ev.Key, ev.Value)
+		case response.EventHeadersDone:
+			// from here on only the body is read; interim responses still
+			// count against Timeout
+			if statusLine.StatusCode >= 200 || statusLine.StatusCode == 101 {
+				pc.SetDeadline(time.Time{})
+				pc.readTimeout = c.ReadTimeout
+			}
+		}
+		return fn(ev)
+	})
+	if err != nil {
+		pc.Close()
+		return started, err
+	}
+
+	if c.Pool != nil && keepAlive(req.Headers, statusLine, h) && !pc.reader.Closed() {
+		c.Pool.put(pc)
+	} else {
+		pc.Close()
+	}
+
+	return started, nil
+}
+
+func (c *Client) dial(t target) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout: c.Timeout,
+	}
+
+	addr := t.addr
+	if t.dialAddr != "" {
+		addr = t.dialAddr
+	}
+
+	if t.scheme == "https" {
+		host, _, _ := net.SplitHostPort(t.addr)
+		return tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			ServerName: host,
+		})
+	}
+
+	return dialer.Dial("tcp", addr)
+}
+
+func keepAlive(reqHeaders headers.Headers, statusLine response.StatusLine, resHeaders headers.Headers) bool {
+	if reqHeaders.HasToken("connection", "close") || resHeaders.HasToken("connection", "close") {
+		return false
+	}
+
+	if statusLine.HttpVersion == "1.0" {
+		return resHeaders.HasToken("connection", "keep-alive")
+	}
+
+	return statusLine.StatusCode != 101
+}
+
+func requestTarget(req *request.Request) (target, error) {
+	rawTarget := req.RequestLine.RequestTarget
+	if strings.HasPrefix(rawTarget, "http://") || strings.HasPrefix(rawTarget, "https://") {
+		return parseURL(rawTarget)
+	}
+
+	host, ok := req.Headers.Get("host")
+	if !ok || host == "" {
+		return target{}, ErrMissingHost
+	}
+
+	return target{
+		scheme: "http",
+		host:   host,
+		addr:   withPort(host, "http"),
+		path:   rawTarget,
+	}, nil
+}
+
+func parseURL(rawURL string) (target, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return target{}, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return target{}, ErrUnsupportedScheme
+	}
+
+	if u.Host == "" {
+		return target{}, ErrMissingHost
+	}
+
+	return target{
+		scheme: u.Scheme,
+		host:   u.Host,
+		addr:   withPort(u.Host, u.Scheme),
+		path:   u.RequestURI(),
+	}, nil
+}
+
+func withPort(host string, scheme string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
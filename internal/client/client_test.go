@@ -0,0 +1,112 @@
+package client
+
+import (
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve answers a single connection with the canned reply and hands the
+// parsed request back.
+func serve(t *testing.T, reply string) (string, <-chan *request.Request) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	requests := make(chan *request.Request, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r, err := request.RequestFromReader(conn)
+		if err != nil {
+			close(requests)
+			return
+		}
+		requests <- r
+		conn.Write([]byte(reply))
+	}()
+
+	return listener.Addr().String(), requests
+}
+
+func TestDoContentLength(t *testing.T) {
+	addr, requests := serve(t, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\nContent-Type: text/plain\r\n\r\nhello")
+
+	req, err := NewRequest("POST", "http://"+addr+"/submit?x=1", []byte("ping"))
+	require.NoError(t, err)
+
+	res, err := NewClient().Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1", res.StatusLine.HttpVersion)
+	assert.EqualValues(t, 200, res.StatusLine.StatusCode)
+	assert.Equal(t, "OK", res.StatusLine.Reason)
+	assert.Equal(t, "text/plain", res.Headers["content-type"])
+	assert.Equal(t, "hello", string(res.Body))
+
+	r := <-requests
+	require.NotNil(t, r)
+	assert.Equal(t, "POST", r.RequestLine.Method)
+	assert.Equal(t, "/submit?x=1", r.RequestLine.RequestTarget)
+	assert.Equal(t, addr, r.Headers["host"])
+	assert.Equal(t, "ping", string(r.Body))
+}
+
+func TestDoChunked(t *testing.T) {
+	addr, _ := serve(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Content-Length\r\n\r\n"+
+		"6\r\nhello \r\n6\r\nworld!\r\n0\r\nX-Content-Length: 12\r\n\r\n")
+
+	res, err := Get("http://" + addr + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!", string(res.Body))
+	assert.Equal(t, "12", res.Trailers["x-content-length"])
+}
+
+func TestDoCloseDelimited(t *testing.T) {
+	addr, _ := serve(t, "HTTP/1.0 200 OK\r\nContent-Type: text/plain\r\n\r\nuntil the connection closes")
+
+	res, err := Get("http://" + addr + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", res.StatusLine.HttpVersion)
+	assert.Equal(t, "until the connection closes", string(res.Body))
+}
+
+func TestDoHead(t *testing.T) {
+	addr, _ := serve(t, "HTTP/1.1 200 OK\r\nContent-Length: 1024\r\n\r\n")
+
+	req, err := NewRequest("HEAD", "http://"+addr+"/", nil)
+	require.NoError(t, err)
+
+	res, err := NewClient().Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "1024", res.Headers["content-length"])
+	assert.Empty(t, res.Body)
+}
+
+func TestDoSkipsInterimResponses(t *testing.T) {
+	addr, _ := serve(t, "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+
+	res, err := Get("http://" + addr + "/missing")
+	require.NoError(t, err)
+	assert.EqualValues(t, 404, res.StatusLine.StatusCode)
+	assert.Equal(t, "Not Found", res.StatusLine.Reason)
+}
+
+func TestDoErrors(t *testing.T) {
+	addr, _ := serve(t, "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nshort")
+
+	_, err := Get("http://" + addr + "/")
+	assert.Equal(t, response.ErrWrongBodyLength, err)
+
+	_, err = Get("ftp://" + addr + "/")
+	assert.Equal(t, ErrUnsupportedScheme, err)
+}
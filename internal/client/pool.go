@@ -0,0 +1,164 @@
+package client
+
+import (
+	"errors"
+	"httpfromtcp/internal/response"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMaxIdlePerHost = 2
+const defaultIdleTimeout = 90 * time.Second
+const healthCheckTimeout = time.Millisecond
+
+// conn is a connection that can be kept alive between requests together with
+// the reader holding its unparsed bytes.
+type conn struct {
+	net.Conn
+	key    string
+	reader *response.Reader
+	idleAt time.Time
+	// readTimeout is set while a response body is read
+	readTimeout time.Duration
+}
+
+type Stats struct {
+	Hits    int64
+	Misses  int64
+	Evicted int64
+	Idle    int
+}
+
+// Pool keeps idle keep-alive connections keyed by scheme, host and port.
+type Pool struct {
+	MaxIdlePerHost int
+	IdleTimeout    time.Duration
+
+	mu      sync.Mutex
+	idle    map[string][]*conn
+	hits    atomic.Int64
+	misses  atomic.Int64
+	evicted atomic.Int64
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		MaxIdlePerHost: defaultMaxIdlePerHost,
+		IdleTimeout:    defaultIdleTimeout,
+		idle:           map[string][]*conn{},
+	}
+}
+
+func newConn(c net.Conn, key string) *conn {
+	pc := &conn{
+		Conn: c,
+		key:  key,
+	}
+	pc.reader = response.NewReader(pc)
+	return pc
+}
+
+// Read pushes the read deadline out before every read while readTimeout is
+// set, so a steady stream never times out but a stalled one does.
+func (c *conn) Read(p []byte) (int, error) {
+	if c.readTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	return c.Conn.Read(p)
+}
+
+// get returns the most recently used healthy idle connection for key, or
+// nil when there is none.
+func (p *Pool) get(key string) *conn {
+	for {
+		p.mu.Lock()
+		conns := p.idle[key]
+		if len(conns) == 0 {
+			p.mu.Unlock()
+			p.misses.Add(1)
+			return nil
+		}
+
+		c := conns[len(conns)-1]
+		p.idle[key] = conns[:len(conns)-1]
+		p.mu.Unlock()
+
+		if p.expired(c) || !c.healthy() {
+			p.evicted.Add(1)
+			c.Close()
+			continue
+		}
+
+		p.hits.Add(1)
+		return c
+	}
+}
+
+func (p *Pool) put(c *conn) {
+	c.readTimeout = 0
+	c.SetDeadline(time.Time{})
+	c.idleAt = time.Now()
+
+	p.mu.Lock()
+	conns := p.idle[c.key]
+	if len(conns) >= p.MaxIdlePerHost {
+		p.mu.Unlock()
+		p.evicted.Add(1)
+		c.Close()
+		return
+	}
+	p.idle[c.key] = append(conns, c)
+	p.mu.Unlock()
+}
+
+func (p *Pool) expired(c *conn) bool {
+	return p.IdleTimeout > 0 && time.Since(c.idleAt) > p.IdleTimeout
+}
+
+// CloseIdle closes every idle connection.
+func (p *Pool) CloseIdle() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = map[string][]*conn{}
+	p.mu.Unlock()
+
+	for _, conns := range idle {
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+}
+
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	idle := 0
+	for _, conns := range p.idle {
+		idle += len(conns)
+	}
+	p.mu.Unlock()
+
+	return Stats{
+		Hits:    p.hits.Load(),
+		Misses:  p.misses.Load(),
+		Evicted: p.evicted.Load(),
+		Idle:    idle,
+	}
+}
+
+// healthy checks an idle connection was neither closed by the server nor
+// received unsolicited data while it sat in the pool.
+func (c *conn) healthy() bool {
+	if c.reader.Buffered() > 0 || c.reader.Closed() {
+		return false
+	}
+
+	c.SetReadDeadline(time.Now().Add(healthCheckTimeout))
+	defer c.SetReadDeadline(time.Time{})
+
+	var b [1]byte
+	n, err := c.Conn.Read(b[:])
+	return n == 0 && errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"httpfromtcp/internal/request"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveKeepAlive answers every request on every connection with reply until
+// the client goes away; closeAfter > 0 closes a connection after that many
+// requests.
+func serveKeepAlive(t *testing.T, reply string, closeAfter int) (string, *atomic.Int64) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	accepted := &atomic.Int64{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+
+			go func() {
+				defer conn.Close()
+				for i := 1; ; i++ {
+					if _, err := request.RequestFromReader(conn); err != nil {
+						return
+					}
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+					if i == closeAfter {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), accepted
+}
+
+func TestPoolReusesConnection(t *testing.T) {
+	addr, accepted := serveKeepAlive(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok", 0)
+	c := NewClient()
+
+	for i := 0; i < 3; i++ {
+		res, err := Get("http://" + addr + "/")
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(res.Body))
+	}
+
+	for i := 0; i < 3; i++ {
+		req, err := NewRequest("GET", "http://"+addr+"/", nil)
+		require.NoError(t, err)
+		res, err := c.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(res.Body))
+	}
+
+	stats := c.Pool.Stats()
+	assert.EqualValues(t, 2, stats.Hits)
+	assert.EqualValues(t, 1, stats.Misses)
+	assert.Equal(t, 1, stats.Idle)
+	// one connection for DefaultClient and one for c
+	assert.EqualValues(t, 2, accepted.Load())
+}
+
+func TestPoolEvictsClosedConnection(t *testing.T) {
+	addr, accepted := serveKeepAlive(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok", 1)
+	c := NewClient()
+
+	for i := 0; i < 2; i++ {
+		req, err := NewRequest("GET", "http://"+addr+"/", nil)
+		require.NoError(t, err)
+		res, err := c.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, "ok", string(res.Body))
+		// let the server close its side
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	stats := c.Pool.Stats()
+	assert.EqualValues(t, 0, stats.Hits)
+	assert.EqualValues(t, 2, stats.Misses)
+	assert.EqualValues(t, 1, stats.Evicted)
+	assert.EqualValues(t, 2, accepted.Load())
+}
+
+func TestPoolConnectionClose(t *testing.T) {
+	addr, accepted := serveKeepAlive(t, "HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 2\r\n\r\nok", 0)
+	c := NewClient()
+
+	for i := 0; i < 2; i++ {
+		req, err := NewRequest("GET", "http://"+addr+"/", nil)
+		require.NoError(t, err)
+		_, err = c.Do(req)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 0, c.Pool.Stats().Idle)
+	assert.EqualValues(t, 2, accepted.Load())
+}
+
+func TestPoolIdleLimits(t *testing.T) {
+	p := NewPool()
+	p.MaxIdlePerHost = 1
+	p.IdleTimeout = time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		client, server := net.Pipe()
+		defer server.Close()
+		p.put(newConn(client, "http://example.com:80"))
+	}
+
+	stats := p.Stats()
+	assert.Equal(t, 1, stats.Idle)
+	assert.EqualValues(t, 1, stats.Evicted)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Nil(t, p.get("http://example.com:80"))
+
+	stats = p.Stats()
+	assert.Equal(t, 0, stats.Idle)
+	assert.EqualValues(t, 2, stats.Evicted)
+	assert.EqualValues(t, 1, stats.Misses)
+}
@@ -0,0 +1,420 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+)
+
+const defaultMinSize = 1024
+
+var defaultContentTypes = []string{
+	"text/html",
+	"text/plain",
+	"text/css",
+	"text/csv",
+	"text/javascript",
+	"text/xml",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// Compressor encodes response bodies with gzip or deflate when the client
+// accepts it. Handlers keep writing plain HTTP; the wrapping writer parses
+// what they write and re-frames compressed bodies as chunked.
+type Compressor struct {
+	// MinSize is the smallest body worth compressing. Bodies of unknown
+	// length are held back until MinSize bytes arrived.
+	MinSize int
+	// ContentTypes lists the media types to compress; an entry ending in
+	// "/*" matches a whole type.
+	ContentTypes []string
+	// Level is passed to the encoders; zero means their default.
+	Level int
+}
+
+func NewCompressor() *Compressor {
+	return &Compressor{
+		MinSize:      defaultMinSize,
+		ContentTypes: defaultContentTypes,
+	}
+}
+
+// Wrap returns a handler compressing what next writes.
+func (c *Compressor) Wrap(next server.Handler) server.Handler {
+	return func(w io.Writer, r *request.Request) {
+		acceptEncoding, _ := r.Headers.Get("accept-encoding")
+
+		cw := &writer{
+			c:        c,
+			w:        w,
+			method:   r.RequestLine.Method,
+			encoding: NegotiateEncoding(acceptEncoding),
+			parser:   response.NewParser(r.RequestLine.Method),
+		}
+
+		next(cw, r)
+		cw.close()
+	}
+}
+
+func (c *Compressor) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+
+	for _, allowed := range c.ContentTypes {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Compressor) newEncoder(encoding string, w io.Writer) (encoder, error) {
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	switch encoding {
+	case Gzip:
+		return gzip.NewWriterLevel(w, level)
+	case Deflate:
+		// HTTP's deflate is the zlib format
+		return zlib.NewWriterLevel(w, level)
+	}
+	return nil, request.ErrUnsupportedEncoding
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type writeMode int
+
+var (
+	// the response head or the first MinSize bytes are still coming in
+	modeUndecided writeMode = 0
+	// bytes go to the connection untouched
+	modePassthrough writeMode = 1
+	// body chunks go through the encoder
+	modeCompress writeMode = 2
+	// the compressed response is complete
+	modeDone writeMode = 3
+)
+
+type writer struct {
+	c        *Compressor
+	w        io.Writer
+	method   string
+	encoding string
+	mode     writeMode
+
+	parser     *response.Parser
+	unparsed   []byte
+	raw        []byte
+	statusLine response.StatusLine
+	headers    headers.Headers
+	trailers   headers.Headers
+	body       []byte
+
+	rw  *response.Writer
+	enc encoder
+	err error
+}
+
+func (cw *writer) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	switch cw.mode {
+	case modePassthrough, modeDone:
+		return cw.w.Write(p)
+	case modeUndecided:
+		cw.raw = append(cw.raw, p...)
+	}
+
+	if err := cw.feed(p); err != nil {
+		cw.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom keeps sendfile working for responses that pass through.
+func (cw *writer) ReadFrom(r io.Reader) (int64, error) {
+	if cw.mode == modePassthrough {
+		if rf, ok := cw.w.(io.ReaderFrom); ok {
+			return rf.ReadFrom(r)
+		}
+		return io.Copy(cw.w, r)
+	}
+	return io.Copy(struct{ io.Writer }{cw}, r)
+}
+
+// Flush pushes out what the encoder holds so streamed responses keep
+// flowing.
+func (cw *writer) Flush() error {
+	if cw.mode == modeCompress {
+		if err := cw.enc.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if f, ok := cw.w.(response.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Hijack hands the connection over; nothing is compressed afterwards.
+func (cw *writer) Hijack() (net.Conn, []byte, error) {
+	hijacker, ok := cw.w.(server.Hijacker)
+	if !ok {
+		return nil, nil, server.ErrNotHijackable
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err == nil {
+		cw.mode = modePassthrough
+	}
+	return conn, buffered, err
+}
+
+func (cw *writer) feed(p []byte) error {
+	cw.unparsed = append(cw.unparsed, p...)
+
+	n, err := cw.parser.Feed(cw.unparsed)
+	if err != nil {
+		// output we can't parse is the handler's business; pass it on if
+		// nothing was rewritten yet
+		if cw.mode == modeUndecided {
+			return cw.passthrough(false)
+		}
+		return err
+	}
+
+	for ev, ok := cw.parser.Next(); ok; ev, ok = cw.parser.Next() {
+		if err := cw.handle(ev); err != nil {
+			return err
+		}
+		if cw.mode == modePassthrough {
+			cw.unparsed = nil
+			return nil
+		}
+	}
+
+	cw.unparsed = append(cw.unparsed[:0], cw.unparsed[n:]...)
+	return nil
+}
+
+func (cw *writer) handle(ev response.Event) error {
+	switch ev.Type {
+	case response.EventStatusLine:
+		cw.statusLine = ev.StatusLine
+		cw.headers = headers.NewHeaders()
+		cw.trailers = headers.NewHeaders()
+	case response.EventHeader:
+		cw.headers.Add(ev.Key, ev.Value)
+	case response.EventHeadersDone:
+		return cw.headersDone()
+	case response.EventBodyChunk:
+		if cw.mode == modeCompress {
+			_, err := cw.enc.Write(ev.Data)
+			return err
+		}
+		cw.body = append(cw.body, ev.Data...)
+		if len(cw.body) >= cw.c.MinSize {
+			return cw.startCompression()
+		}
+	case response.EventTrailer:
+		cw.trailers.Add(ev.Key, ev.Value)
+	case response.EventComplete:
+		if cw.mode == modeUndecided {
+			if len(cw.body) < cw.c.MinSize {
+				return cw.passthrough(true)
+			}
+			if err := cw.startCompression(); err != nil {
+				return err
+			}
+		}
+		return cw.finish()
+	}
+	return nil
+}
+
+// headersDone decides whatever can be decided from the head alone.
+func (cw *writer) headersDone() error {
+	if !cw.eligible() {
+		return cw.passthrough(false)
+	}
+
+	if cw.encoding == "" {
+		return cw.passthrough(true)
+	}
+
+	if length, err := cw.headers.ContentLength(); err != nil || length >= 0 {
+		if err == nil && length < int64(cw.c.MinSize) {
+			return cw.passthrough(true)
+		}
+		return cw.startCompression()
+	}
+
+	// the length is unknown: wait for MinSize bytes or the end
+	return nil
+}
+
+// eligible reports whether the response could be compressed for some
+// client, which is also when it varies on Accept-Encoding.
+func (cw *writer) eligible() bool {
+	code := cw.statusLine.StatusCode
+	if code < 200 || code == 204 || code == 206 || code == 304 || cw.method == "HEAD" {
+		return false
+	}
+
+	if _, ok := cw.headers.Get("content-encoding"); ok {
+		return false
+	}
+	if _, ok := cw.headers.Get("content-range"); ok {
+		return false
+	}
+	if cacheControl, ok := cw.headers.Get("cache-control"); ok && strings.Contains(strings.ToLower(cacheControl), "no-transform") {
+		return false
+	}
+
+	contentType, _ := cw.headers.Get("content-type")
+	return cw.c.compressible(contentType)
+}
+
+// passthrough sends everything written so far unchanged, optionally adding
+// Vary to the head.
+func (cw *writer) passthrough(vary bool) error {
+	cw.mode = modePassthrough
+
+	raw := cw.raw
+	cw.raw = nil
+	cw.body = nil
+
+	if vary {
+		if end := bytes.Index(raw, []byte("\r\n\r\n")); end >= 0 {
+			head := append([]byte(nil), raw[:end+2]...)
+			head = append(head, "vary: Accept-Encoding\r\n"...)
+			raw = append(head, raw[end+2:]...)
+		}
+	}
+
+	_, err := cw.w.Write(raw)
+	return err
+}
+
+func (cw *writer) startCompression() error {
+	h := cw.headers
+	delete(h, "content-length")
+	h["transfer-encoding"] = "chunked"
+	h["content-encoding"] = cw.encoding
+	h.Add("vary", "Accept-Encoding")
+
+	// the encoded bytes differ, so a strong validator no longer holds
+	if etag, ok := h.Get("etag"); ok && !strings.HasPrefix(etag, "W/") {
+		h["etag"] = "W/" + etag
+	}
+
+	cw.rw = response.NewWriter(cw.w)
+	if err := cw.rw.WriteStatusLine(cw.statusLine.StatusCode); err != nil {
+		return err
+	}
+	if err := cw.rw.WriteHeaders(h); err != nil {
+		return err
+	}
+
+	enc, err := cw.c.newEncoder(cw.encoding, chunkWriter{cw.rw})
+	if err != nil {
+		return err
+	}
+	cw.enc = enc
+	cw.mode = modeCompress
+	cw.raw = nil
+
+	body := cw.body
+	cw.body = nil
+	_, err = cw.enc.Write(body)
+	return err
+}
+
+func (cw *writer) finish() error {
+	if err := cw.enc.Close(); err != nil {
+		return err
+	}
+	cw.mode = modeDone
+
+	if len(cw.trailers) == 0 {
+		_, err := cw.rw.WriteChunkedBodyDone(false)
+		return err
+	}
+
+	if _, err := cw.rw.WriteChunkedBodyDone(true); err != nil {
+		return err
+	}
+	return cw.rw.WriteTrailers(cw.trailers)
+}
+
+// close runs after the handler returned and settles responses whose end
+// wasn't seen, like close-delimited ones.
+func (cw *writer) close() {
+	if cw.err != nil {
+		return
+	}
+
+	switch cw.mode {
+	case modeUndecided:
+		if cw.parser.State() == response.ParsedHeaders && cw.parser.Finish() == nil {
+			for ev, ok := cw.parser.Next(); ok; ev, ok = cw.parser.Next() {
+				if err := cw.handle(ev); err != nil {
+					return
+				}
+			}
+			return
+		}
+		cw.passthrough(false)
+	case modeCompress:
+		// a close-delimited body ends here; anything else was cut short and
+		// is left without its last chunk so the client sees the truncation
+		if cw.parser.CloseDelimited() {
+			cw.finish()
+		}
+	}
+}
+
+// chunkWriter writes each encoder output as one chunk.
+type chunkWriter struct {
+	rw *response.Writer
+}
+
+func (c chunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if _, err := c.rw.WriteChunkedBody(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
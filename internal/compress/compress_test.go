@@ -0,0 +1,356 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"gzip", Gzip},
+		{"deflate", Deflate},
+		{"deflate, gzip", Gzip},
+		{"gzip;q=0.5, deflate", Deflate},
+		{"GZIP;Q=0.8, deflate;q=0.8", Gzip},
+		{"br, zstd", ""},
+		{"*", Gzip},
+		{"*;q=0.1, gzip;q=0", Deflate},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"identity", ""},
+		{"x-gzip", Gzip},
+		{"gzip;q=2", ""},
+		{"gzip;q=abc, deflate;q=0.1", Deflate},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NegotiateEncoding(tc.value))
+		})
+	}
+}
+
+func fixedHandler(contentType string, body string) func(io.Writer, *request.Request) {
+	return func(w io.Writer, r *request.Request) {
+		rw := response.NewWriter(w)
+		rw.WriteStatusLine(response.OK)
+		h := headers.NewHeaders()
+		h.SetDefault(len(body), map[string]string{
+			"content-type": contentType,
+			"etag":         `"v1"`,
+		})
+		rw.WriteHeaders(h)
+		rw.WriteBody([]byte(body))
+	}
+}
+
+func chunkedHandler(parts ...string) func(io.Writer, *request.Request) {
+	return func(w io.Writer, r *request.Request) {
+		rw := response.NewWriter(w)
+		rw.WriteStatusLine(response.OK)
+		h := headers.NewHeaders()
+		h["content-type"] = "text/plain"
+		h["transfer-encoding"] = "chunked"
+		h["trailer"] = "x-checksum"
+		rw.WriteHeaders(h)
+		for _, p := range parts {
+			rw.WriteChunkedBody([]byte(p))
+		}
+		rw.WriteChunkedBodyDone(true)
+		rw.WriteTrailers(headers.Headers{"x-checksum": "abc"})
+	}
+}
+
+func closeDelimitedHandler(body string) func(io.Writer, *request.Request) {
+	return func(w io.Writer, r *request.Request) {
+		w.Write([]byte("HTTP/1.1 200 OK\r\ncontent-type: text/html\r\nconnection: close\r\n\r\n" + body))
+	}
+}
+
+func serve(t *testing.T, c *Compressor, handler func(io.Writer, *request.Request), method string, acceptEncoding string) *response.Response {
+	t.Helper()
+
+	r := &request.Request{
+		RequestLine: request.RequestLine{Method: method, RequestTarget: "/", HttpVersion: "1.1"},
+		Headers:     headers.NewHeaders(),
+	}
+	if acceptEncoding != "" {
+		r.Headers["accept-encoding"] = acceptEncoding
+	}
+
+	var buf bytes.Buffer
+	c.Wrap(handler)(&buf, r)
+
+	res, err := response.NewReader(&buf).ReadResponse(method)
+	require.NoError(t, err)
+	return res
+}
+
+func decode(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	var err error
+	switch encoding {
+	case Gzip:
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case Deflate:
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCompressor(t *testing.T) {
+	large := strings.Repeat("<p>compress me</p>", 200)
+	small := "<p>tiny</p>"
+
+	tests := []struct {
+		name           string
+		handler        func(io.Writer, *request.Request)
+		method         string
+		acceptEncoding string
+		encoding       string
+		vary           bool
+		body           string
+		trailer        string
+	}{
+		{
+			name:           "Gzip with content length",
+			handler:        fixedHandler("text/html; charset=utf-8", large),
+			acceptEncoding: "gzip, deflate",
+			encoding:       Gzip,
+			vary:           true,
+			body:           large,
+		},
+		{
+			name:           "Deflate",
+			handler:        fixedHandler("application/json", large),
+			acceptEncoding: "deflate",
+			encoding:       Deflate,
+			vary:           true,
+			body:           large,
+		},
+		{
+			name:           "Below minimum size",
+			handler:        fixedHandler("text/html", small),
+			acceptEncoding: "gzip",
+			vary:           true,
+			body:           small,
+		},
+		{
+			name:    "Client doesn't accept",
+			handler: fixedHandler("text/html", large),
+			vary:    true,
+			body:    large,
+		},
+		{
+			name:           "Type not allowed",
+			handler:        fixedHandler("video/mp4", large),
+			acceptEncoding: "gzip",
+			body:           large,
+		},
+		{
+			name:           "Head",
+			handler:        fixedHandler("text/html", large),
+			method:         "HEAD",
+			acceptEncoding: "gzip",
+		},
+		{
+			name:           "Chunked with trailers",
+			handler:        chunkedHandler(large[:700], large[700:]),
+			acceptEncoding: "gzip",
+			encoding:       Gzip,
+			vary:           true,
+			body:           large,
+			trailer:        "abc",
+		},
+		{
+			name:           "Small chunked body",
+			handler:        chunkedHandler("a", "b"),
+			acceptEncoding: "gzip",
+			vary:           true,
+			body:           "ab",
+			trailer:        "abc",
+		},
+		{
+			name:           "Close delimited",
+			handler:        closeDelimitedHandler(large),
+			acceptEncoding: "gzip",
+			encoding:       Gzip,
+			vary:           true,
+			body:           large,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = "GET"
+			}
+
+			res := serve(t, NewCompressor(), tc.handler, method, tc.acceptEncoding)
+			assert.Equal(t, response.OK, res.StatusLine.StatusCode)
+			assert.Equal(t, tc.encoding, res.Headers["content-encoding"])
+			if tc.vary {
+				assert.Equal(t, "Accept-Encoding", res.Headers["vary"])
+			} else {
+				assert.NotContains(t, res.Headers, "vary")
+			}
+			assert.Equal(t, tc.body, decode(t, tc.encoding, res.Body))
+			assert.Equal(t, tc.trailer, res.Trailers["x-checksum"])
+
+			if tc.encoding != "" {
+				assert.NotContains(t, res.Headers, "content-length")
+				assert.Equal(t, "chunked", res.Headers["transfer-encoding"])
+				assert.Less(t, len(res.Body), len(tc.body))
+				if etag, ok := res.Headers["etag"]; ok {
+					assert.Equal(t, `W/"v1"`, etag)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressorKeepsExistingEncoding(t *testing.T) {
+	handler := func(w io.Writer, r *request.Request) {
+		body := strings.Repeat("x", 2000)
+		fmt.Fprintf(w, "HTTP/1.1 200 OK\r\ncontent-type: text/plain\r\ncontent-encoding: br\r\ncontent-length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	res := serve(t, NewCompressor(), handler, "GET", "gzip")
+	assert.Equal(t, "br", res.Headers["content-encoding"])
+	assert.Equal(t, strings.Repeat("x", 2000), string(res.Body))
+}
+
+func TestCompressorTruncatedBody(t *testing.T) {
+	handler := func(w io.Writer, r *request.Request) {
+		fmt.Fprintf(w, "HTTP/1.1 200 OK\r\ncontent-type: text/plain\r\ncontent-length: 5000\r\n\r\n%s", strings.Repeat("x", 2000))
+	}
+
+	r := &request.Request{
+		RequestLine: request.RequestLine{Method: "GET", RequestTarget: "/", HttpVersion: "1.1"},
+		Headers:     headers.Headers{"accept-encoding": "gzip"},
+	}
+	var buf bytes.Buffer
+	NewCompressor().Wrap(handler)(&buf, r)
+
+	_, err := response.ResponseFromReader(&buf)
+	assert.Equal(t, response.ErrIncompleteResponse, err)
+}
+
+func TestCompressorFlush(t *testing.T) {
+	var buf bytes.Buffer
+	flushed := 0
+
+	handler := func(w io.Writer, r *request.Request) {
+		rw := response.NewWriter(w)
+		rw.WriteStatusLine(response.OK)
+		h := headers.NewHeaders()
+		h["content-type"] = "text/plain"
+		h["transfer-encoding"] = "chunked"
+		rw.WriteHeaders(h)
+
+		rw.WriteChunkedBody([]byte(strings.Repeat("event\n", 200)))
+		require.NoError(t, rw.Flush())
+		flushed = buf.Len()
+
+		rw.WriteChunkedBody([]byte("last\n"))
+		rw.WriteChunkedBodyDone(false)
+	}
+
+	r := &request.Request{
+		RequestLine: request.RequestLine{Method: "GET", RequestTarget: "/", HttpVersion: "1.1"},
+		Headers:     headers.Headers{"accept-encoding": "gzip"},
+	}
+	NewCompressor().Wrap(handler)(&buf, r)
+
+	// a flush has to push compressed bytes out, not just the head
+	head := strings.Index(buf.String(), "\r\n\r\n") + 4
+	assert.Greater(t, flushed, head)
+
+	res, err := response.ResponseFromReader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("event\n", 200)+"last\n", decode(t, Gzip, res.Body))
+}
+
+func TestDecompressor(t *testing.T) {
+	plain := strings.Repeat("upload ", 300)
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write([]byte(plain))
+	gw.Close()
+
+	tests := []struct {
+		name           string
+		encoding       string
+		body           []byte
+		maxSize        int64
+		expectedStatus response.StatusCode
+	}{
+		{"Gzip", "gzip", gzipped.Bytes(), 0, response.OK},
+		{"Plain", "", []byte(plain), 0, response.OK},
+		{"Unsupported", "br", gzipped.Bytes(), 0, response.UNSUPPORTED_MEDIA_TYPE},
+		{"Too large", "gzip", gzipped.Bytes(), 100, response.CONTENT_TOO_LARGE},
+		{"Corrupt", "gzip", []byte("garbage"), 0, response.BAD_REQUEST},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &request.Request{
+				RequestLine: request.RequestLine{Method: "POST", RequestTarget: "/", HttpVersion: "1.1"},
+				Headers:     headers.NewHeaders(),
+				Body:        tc.body,
+			}
+			if tc.encoding != "" {
+				r.Headers["content-encoding"] = tc.encoding
+			}
+
+			var received string
+			handler := func(w io.Writer, r *request.Request) {
+				received = string(r.Body)
+				fixedHandler("text/plain", "ok")(w, r)
+			}
+
+			d := NewDecompressor()
+			if tc.maxSize > 0 {
+				d.MaxSize = tc.maxSize
+			}
+
+			var buf bytes.Buffer
+			d.Wrap(handler)(&buf, r)
+
+			res, err := response.ResponseFromReader(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, res.StatusLine.StatusCode)
+			if tc.expectedStatus == response.OK {
+				assert.Equal(t, plain, received)
+			} else {
+				assert.Empty(t, received)
+			}
+			if tc.expectedStatus == response.UNSUPPORTED_MEDIA_TYPE {
+				assert.Equal(t, "gzip, deflate", res.Headers["accept-encoding"])
+			}
+		})
+	}
+}
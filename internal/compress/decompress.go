@@ -0,0 +1,45 @@
+package compress
+
+import (
+	"errors"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+)
+
+const defaultMaxDecodedSize = 10 << 20
+
+// Decompressor decodes gzip and deflate request bodies before handlers see
+// them.
+type Decompressor struct {
+	// MaxSize limits decoded bodies; zero means no limit.
+	MaxSize int64
+}
+
+func NewDecompressor() *Decompressor {
+	return &Decompressor{
+		MaxSize: defaultMaxDecodedSize,
+	}
+}
+
+// Wrap returns a handler passing decoded requests to next. Unsupported
+// codings get 415, bodies decoding past MaxSize 413 and corrupt ones 400.
+func (d *Decompressor) Wrap(next server.Handler) server.Handler {
+	return func(w io.Writer, r *request.Request) {
+		err := r.DecodeBody(d.MaxSize)
+		switch {
+		case err == nil:
+			next(w, r)
+		case errors.Is(err, request.ErrUnsupportedEncoding):
+			// tell the client what it could have used
+			response.WriteErrorHeaders(w, response.UNSUPPORTED_MEDIA_TYPE, map[string]string{
+				"accept-encoding": "gzip, deflate",
+			}, err)
+		case errors.Is(err, request.ErrDecodedBodyTooLarge):
+			response.WriteError(w, response.CONTENT_TOO_LARGE, err)
+		default:
+			response.WriteError(w, response.BAD_REQUEST, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package compress
+
+import (
+	"httpfromtcp/internal/headers"
+	"strings"
+)
+
+var (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// supported lists the codings we can produce, most preferred first.
+var supported = []string{Gzip, Deflate}
+
+// NegotiateEncoding picks the content coding for an Accept-Encoding value:
+// the supported coding with the highest q-value, preferring gzip on ties.
+// It returns "" when the response should not be encoded.
+func NegotiateEncoding(acceptEncoding string) string {
+	weights := map[string]float64{}
+	wildcard := -1.0
+
+	for _, item := range headers.ParseAccept(acceptEncoding) {
+		switch coding := strings.ToLower(item.Value); coding {
+		case "*":
+			wildcard = max(wildcard, item.Q)
+		case "x-gzip":
+			// RFC 9110 section 8.4.1.3 treats x-gzip as gzip
+			weights[Gzip] = max(weights[Gzip], item.Q)
+		default:
+			weights[coding] = max(weights[coding], item.Q)
+		}
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, coding := range supported {
+		q, ok := weights[coding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best = coding
+			bestQ = q
+		}
+	}
+
+	return best
+}
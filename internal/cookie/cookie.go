@@ -0,0 +1,232 @@
+package cookie
+
+import (
+	"errors"
+	"httpfromtcp/internal/headers"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCookie        = errors.New("no such cookie")
+	ErrWrongName       = errors.New("invalid cookie name")
+	ErrWrongValue      = errors.New("invalid cookie value")
+	ErrWrongPath       = errors.New("invalid cookie path")
+	ErrWrongDomain     = errors.New("invalid cookie domain")
+	ErrWrongExpires    = errors.New("invalid cookie expiry")
+	ErrInsecure        = errors.New("cookie has to be secure")
+	ErrWrongHostPrefix = errors.New("__Host- cookie needs path / and no domain")
+)
+
+type SameSite int
+
+var (
+	// SameSiteDefault leaves the attribute out
+	SameSiteDefault SameSite = 0
+	SameSiteLax     SameSite = 1
+	SameSiteStrict  SameSite = 2
+	// SameSiteNone requires Secure
+	SameSiteNone SameSite = 3
+)
+
+type Cookie struct {
+	Name  string
+	Value string
+
+	Path   string
+	Domain string
+	// Expires is left out when zero.
+	Expires time.Time
+	// MaxAge is in seconds: zero leaves it out, a negative value deletes
+	// the cookie right away.
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite SameSite
+	// Partitioned keeps the cookie in per top-level site storage (CHIPS);
+	// it requires Secure.
+	Partitioned bool
+}
+
+// Parse reads the pairs of a Cookie field value. Malformed pairs are
+// skipped rather than failing the whole field.
+func Parse(value string) []*Cookie {
+	var cookies []*Cookie
+
+	for _, pair := range strings.Split(value, ";") {
+		name, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || !headers.IsToken(name) {
+			continue
+		}
+
+		val, ok := parseValue(val)
+		if !ok {
+			continue
+		}
+		cookies = append(cookies, &Cookie{Name: name, Value: val})
+	}
+
+	return cookies
+}
+
+// ReadCookies returns the cookies a request carries, in order.
+func ReadCookies(h headers.Headers) []*Cookie {
+	value, ok := h.Get("cookie")
+	if !ok {
+		return nil
+	}
+	return Parse(value)
+}
+
+// Get returns the first cookie called name.
+func Get(h headers.Headers, name string) (*Cookie, error) {
+	for _, c := range ReadCookies(h) {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, ErrNoCookie
+}
+
+// Set validates c and adds it to h as another Set-Cookie field.
+func Set(h headers.Headers, c *Cookie) error {
+	if err := c.Valid(); err != nil {
+		return err
+	}
+	h.Add("set-cookie", c.String())
+	return nil
+}
+
+// Valid checks c against RFC 6265 and the rules browsers enforce for
+// SameSite=None, Partitioned and the __Secure- and __Host- prefixes.
+func (c *Cookie) Valid() error {
+	if !headers.IsToken(c.Name) {
+		return ErrWrongName
+	}
+	if !isValue(c.Value) {
+		return ErrWrongValue
+	}
+	if !isPath(c.Path) {
+		return ErrWrongPath
+	}
+	if c.Domain != "" && !isDomain(c.Domain) {
+		return ErrWrongDomain
+	}
+	if !c.Expires.IsZero() && c.Expires.Year() < 1601 {
+		return ErrWrongExpires
+	}
+
+	needsSecure := c.SameSite == SameSiteNone || c.Partitioned ||
+		strings.HasPrefix(c.Name, "__Secure-") || strings.HasPrefix(c.Name, "__Host-")
+	if needsSecure && !c.Secure {
+		return ErrInsecure
+	}
+
+	if strings.HasPrefix(c.Name, "__Host-") && (c.Path != "/" || c.Domain != "") {
+		return ErrWrongHostPrefix
+	}
+
+	return nil
+}
+
+// String formats c as a Set-Cookie field value. It doesn't validate; Set
+// does.
+func (c *Cookie) String() string {
+	var b strings.Builder
+	b.WriteString(c.Name)
+	b.WriteByte('=')
+	b.WriteString(c.Value)
+
+	if c.Path != "" {
+		b.WriteString("; Path=" + c.Path)
+	}
+	if c.Domain != "" {
+		b.WriteString("; Domain=" + strings.TrimPrefix(c.Domain, "."))
+	}
+	if !c.Expires.IsZero() {
+		b.WriteString("; Expires=" + headers.FormatTime(c.Expires))
+	}
+	if c.MaxAge > 0 {
+		b.WriteString("; Max-Age=" + strconv.Itoa(c.MaxAge))
+	} else if c.MaxAge < 0 {
+		b.WriteString("; Max-Age=0")
+	}
+	if c.Secure {
+		b.WriteString("; Secure")
+	}
+	if c.HttpOnly {
+		b.WriteString("; HttpOnly")
+	}
+
+	switch c.SameSite {
+	case SameSiteLax:
+		b.WriteString("; SameSite=Lax")
+	case SameSiteStrict:
+		b.WriteString("; SameSite=Strict")
+	case SameSiteNone:
+		b.WriteString("; SameSite=None")
+	}
+
+	if c.Partitioned {
+		b.WriteString("; Partitioned")
+	}
+
+	return b.String()
+}
+
+// parseValue strips the optional quotes around a cookie value.
+func parseValue(s string) (string, bool) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return s, isOctets(s)
+}
+
+func isValue(s string) bool {
+	_, ok := parseValue(s)
+	return ok
+}
+
+// isOctets checks for cookie-octets: visible ASCII except DQUOTE, comma,
+// semicolon and backslash.
+func isOctets(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x21 || c > 0x7e || c == '"' || c == ',' || c == ';' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+func isPath(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] >= 0x7f || s[i] == ';' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDomain accepts host names and IPv4 addresses, with an optional leading
+// dot that is dropped when formatting.
+func isDomain(s string) bool {
+	s = strings.TrimPrefix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package cookie
+
+import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/response"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cookies := Parse(`session=abc123; theme="dark"; bad name=x; empty=; noequals; tab=a	b;  lang=en-US`)
+
+	var pairs []string
+	for _, c := range cookies {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	assert.Equal(t, []string{"session=abc123", "theme=dark", "empty=", "lang=en-US"}, pairs)
+}
+
+func TestGet(t *testing.T) {
+	h := headers.NewHeaders()
+	h.Add("cookie", "a=1; b=2")
+	h.Add("cookie", "a=3")
+
+	c, err := Get(h, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", c.Value)
+	assert.Len(t, ReadCookies(h), 3)
+
+	_, err = Get(h, "missing")
+	assert.ErrorIs(t, err, ErrNoCookie)
+	assert.Nil(t, ReadCookies(headers.NewHeaders()))
+}
+
+func TestString(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name     string
+		cookie   Cookie
+		expected string
+	}{
+		{"Plain", Cookie{Name: "id", Value: "42"}, "id=42"},
+		{
+			"All attributes",
+			Cookie{
+				Name: "id", Value: "42", Path: "/app", Domain: ".example.com", Expires: expires, MaxAge: 3600,
+				Secure: true, HttpOnly: true, SameSite: SameSiteNone, Partitioned: true,
+			},
+			"id=42; Path=/app; Domain=example.com; Expires=Wed, 02 Jan 2030 02:04:05 GMT; Max-Age=3600; Secure; HttpOnly; SameSite=None; Partitioned",
+		},
+		{"Delete", Cookie{Name: "id", MaxAge: -1}, "id=; Max-Age=0"},
+		{"Lax", Cookie{Name: "id", Value: "1", SameSite: SameSiteLax}, "id=1; SameSite=Lax"},
+		{"Strict", Cookie{Name: "id", Value: "1", SameSite: SameSiteStrict}, "id=1; SameSite=Strict"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.NoError(t, tc.cookie.Valid())
+			assert.Equal(t, tc.expected, tc.cookie.String())
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie Cookie
+		err    error
+	}{
+		{"Quoted value", Cookie{Name: "a", Value: `"quoted"`}, nil},
+		{"Empty name", Cookie{Value: "1"}, ErrWrongName},
+		{"Name with separator", Cookie{Name: "a=b", Value: "1"}, ErrWrongName},
+		{"Value with semicolon", Cookie{Name: "a", Value: "1;Secure"}, ErrWrongValue},
+		{"Value with space", Cookie{Name: "a", Value: "1 2"}, ErrWrongValue},
+		{"Value with comma", Cookie{Name: "a", Value: "1,2"}, ErrWrongValue},
+		{"Path with semicolon", Cookie{Name: "a", Path: "/;Domain=evil"}, ErrWrongPath},
+		{"Path with newline", Cookie{Name: "a", Path: "/\r\nx: y"}, ErrWrongPath},
+		{"Domain with port", Cookie{Name: "a", Domain: "example.com:80"}, ErrWrongDomain},
+		{"Domain with empty label", Cookie{Name: "a", Domain: "example..com"}, ErrWrongDomain},
+		{"Ancient expiry", Cookie{Name: "a", Expires: time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC)}, ErrWrongExpires},
+		{"SameSite=None without Secure", Cookie{Name: "a", SameSite: SameSiteNone}, ErrInsecure},
+		{"Partitioned without Secure", Cookie{Name: "a", Partitioned: true}, ErrInsecure},
+		{"__Secure- without Secure", Cookie{Name: "__Secure-a"}, ErrInsecure},
+		{"__Host- with domain", Cookie{Name: "__Host-a", Path: "/", Domain: "example.com", Secure: true}, ErrWrongHostPrefix},
+		{"__Host- without path", Cookie{Name: "__Host-a", Secure: true}, ErrWrongHostPrefix},
+		{"__Host-", Cookie{Name: "__Host-a", Path: "/", Secure: true}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.cookie.Valid(), tc.err)
+		})
+	}
+}
+
+func TestSetWritesSeparateFields(t *testing.T) {
+	h := headers.NewHeaders()
+	require.NoError(t, Set(h, &Cookie{Name: "a", Value: "1", Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}))
+	require.NoError(t, Set(h, &Cookie{Name: "b", Value: "2", HttpOnly: true}))
+	assert.ErrorIs(t, Set(h, &Cookie{Name: "c", Value: "x;y"}), ErrWrongValue)
+
+	var buf bytes.Buffer
+	rw := response.NewWriter(&buf)
+	require.NoError(t, rw.WriteStatusLine(response.OK))
+	require.NoError(t, rw.WriteHeaders(h))
+
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"set-cookie: a=1; Expires=Tue, 01 Jan 2030 00:00:00 GMT\r\n"+
+		"set-cookie: b=2; HttpOnly\r\n\r\n", buf.String())
+
+	res, err := response.ResponseFromReader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, h.Lines("set-cookie"), res.Headers.Lines("set-cookie"))
+}
@@ -0,0 +1,419 @@
+package fileserver
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	indexFile = "index.html"
+	sniffLen  = 512
+)
+
+var (
+	ErrNotFound         = errors.New("file not found")
+	ErrForbidden        = errors.New("access denied")
+	ErrMethodNotAllowed = errors.New("only GET and HEAD are allowed")
+	ErrWrongPath        = errors.New("malformed path")
+
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// FileServer serves files below a root directory. Paths are resolved
+// through os.Root, so neither ".." nor symlinks can reach outside of it.
+type FileServer struct {
+	root *os.Root
+
+	// StripPrefix is removed from request paths before they are looked up.
+	StripPrefix string
+	// Listing renders directories without an index.html.
+	Listing bool
+}
+
+func NewFileServer(dir string) (*FileServer, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileServer{
+		root: root,
+	}, nil
+}
+
+func (s *FileServer) Close() error {
+	return s.root.Close()
+}
+
+func (s *FileServer) Handle(w io.Writer, r *request.Request) {
+	if !allowedMethod(w, r) {
+		return
+	}
+
+	urlPath, name, err := s.resolve(r.RequestLine.RequestTarget)
+	if err != nil {
+		response.WriteError(w, response.NOT_FOUND, err)
+		return
+	}
+
+	s.serve(w, r, urlPath, name, true)
+}
+
+// ServeFile serves name, relative to the root, whatever the request target.
+func (s *FileServer) ServeFile(w io.Writer, r *request.Request, name string) {
+	if !allowedMethod(w, r) {
+		return
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	s.serve(w, r, "", name, false)
+}
+
+// resolve turns a request target into the path shown to clients and a name
+// relative to the root.
+func (s *FileServer) resolve(target string) (string, string, error) {
+	rawPath, _, _ := strings.Cut(target, "?")
+	if !strings.HasPrefix(rawPath, "/") {
+		return "", "", ErrWrongPath
+	}
+
+	p, err := url.PathUnescape(rawPath)
+	if err != nil || strings.ContainsRune(p, 0) {
+		return "", "", ErrWrongPath
+	}
+
+	// the prefix has to end on a segment boundary, "/static" doesn't cover
+	// "/statichello.css"
+	rest, ok := strings.CutPrefix(p, s.StripPrefix)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasSuffix(s.StripPrefix, "/")) {
+		return "", "", ErrNotFound
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+rest), "/")
+	if name == "" {
+		name = "."
+	}
+
+	return p, name, nil
+}
+
+func (s *FileServer) serve(w io.Writer, r *request.Request, urlPath string, name string, dirs bool) {
+	f, err := s.root.Open(name)
+	if err != nil {
+		writeOpenError(w, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		response.WriteError(w, response.SERVER_ERROR, err)
+		return
+	}
+
+	if !info.IsDir() {
+		serveContent(w, r, f, info)
+		return
+	}
+
+	if !dirs {
+		response.WriteError(w, response.NOT_FOUND, ErrNotFound)
+		return
+	}
+
+	// relative links in listings and index pages need the trailing slash
+	if !strings.HasSuffix(urlPath, "/") {
+		redirect(w, (&url.URL{Path: urlPath + "/"}).EscapedPath())
+		return
+	}
+
+	index, err := s.root.Open(path.Join(name, indexFile))
+	if err == nil {
+		defer index.Close()
+
+		indexInfo, err := index.Stat()
+		if err == nil && !indexInfo.IsDir() {
+			serveContent(w, r, index, indexInfo)
+			return
+		}
+	}
+
+	if !s.Listing {
+		response.WriteError(w, response.FORBIDDEN, ErrForbidden)
+		return
+	}
+
+	entries, err := f.ReadDir(-1)
+	if err != nil {
+		response.WriteError(w, response.SERVER_ERROR, err)
+		return
+	}
+
+	writeListing(w, r, urlPath, entries)
+}
+
+func serveContent(w io.Writer, r *request.Request, f *os.File, info fs.FileInfo) {
+	contentType, err := contentType(f)
+	if err != nil {
+		response.WriteError(w, response.SERVER_ERROR, err)
+		return
+	}
+
+	size := info.Size()
+	modTime := info.ModTime()
+	etag := response.FileETag(size, modTime, false)
+	lastModified := headers.FormatTime(modTime)
+
+	switch response.CheckPreconditions(r.RequestLine.Method, r.Headers, etag, modTime) {
+	case response.NOT_MODIFIED:
+		writeNotModified(w, etag, lastModified)
+		return
+	case response.PRECONDITION_FAILED:
+		response.WriteError(w, response.PRECONDITION_FAILED, ErrPreconditionFailed)
+		return
+	}
+
+	h := headers.NewHeaders()
+	h.SetDefault(int(size), map[string]string{
+		"content-type":  contentType,
+		"accept-ranges": "bytes",
+		"etag":          etag,
+		"last-modified": lastModified,
+	})
+
+	rangeValue, hasRange := r.Headers.Get("range")
+	if !hasRange || r.RequestLine.Method != "GET" || !response.IfRange(r.Headers, etag, modTime) {
+		writeFile(w, r, h, f)
+		return
+	}
+
+	ranges, err := ParseRange(rangeValue, size)
+	switch {
+	case errors.Is(err, ErrUnsatisfiableRange):
+		response.WriteErrorHeaders(w, response.RANGE_NOT_SATISFIABLE, map[string]string{
+			"content-range": fmt.Sprintf("bytes */%d", size),
+		}, err)
+		return
+	case err != nil || totalLength(ranges) > size:
+		// broken headers are ignored, and so are ranges asking for more
+		// than the whole file
+		writeFile(w, r, h, f)
+		return
+	}
+
+	rw := response.NewWriter(w)
+	if err := rw.WriteStatusLine(response.PARTIAL_CONTENT); err != nil {
+		fmt.Printf("Write status line error: %v\n", err)
+		return
+	}
+
+	if len(ranges) == 1 {
+		h["content-range"] = ranges[0].contentRange(size)
+		h.SetContentLength(ranges[0].Length)
+		if err := rw.WriteHeaders(h); err != nil {
+			fmt.Printf("Write headers error: %v\n", err)
+			return
+		}
+
+		if err := writeRange(rw, f, ranges[0]); err != nil {
+			fmt.Printf("Write body error: %v\n", err)
+		}
+		return
+	}
+
+	parts := newMultipartRanges(ranges, contentType, size)
+	h["content-type"] = parts.contentType()
+	h.SetContentLength(parts.length)
+	if err := rw.WriteHeaders(h); err != nil {
+		fmt.Printf("Write headers error: %v\n", err)
+		return
+	}
+
+	if err := parts.write(rw, f, ranges); err != nil {
+		fmt.Printf("Write body error: %v\n", err)
+	}
+}
+
+// writeNotModified repeats the validators but sends no content.
+func writeNotModified(w io.Writer, etag string, lastModified string) {
+	rw := response.NewWriter(w)
+	if err := rw.WriteStatusLine(response.NOT_MODIFIED); err != nil {
+		fmt.Printf("Write status line error: %v\n", err)
+		return
+	}
+
+	h := headers.NewHeaders()
+	h["etag"] = etag
+	if lastModified != "" {
+		h["last-modified"] = lastModified
+	}
+	h["connection"] = "close"
+	if err := rw.WriteHeaders(h); err != nil {
+		fmt.Printf("Write headers error: %v\n", err)
+	}
+}
+
+func writeFile(w io.Writer, r *request.Request, h headers.Headers, f *os.File) {
+	rw := response.NewWriter(w)
+	if err := rw.WriteStatusLine(response.OK); err != nil {
+		fmt.Printf("Write status line error: %v\n", err)
+		return
+	}
+
+	if err := rw.WriteHeaders(h); err != nil {
+		fmt.Printf("Write headers error: %v\n", err)
+		return
+	}
+
+	if r.RequestLine.Method == "HEAD" {
+		return
+	}
+
+	if _, err := rw.WriteBodyFrom(f); err != nil {
+		fmt.Printf("Write body error: %v\n", err)
+	}
+}
+
+// contentType guesses from the extension first and sniffs the content
+// otherwise, leaving f positioned at its start.
+func contentType(f *os.File) (string, error) {
+	if ct := mime.TypeByExtension(path.Ext(f.Name())); ct != "" {
+		return ct, nil
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return DetectContentType(buf[:n]), nil
+}
+
+var listingTemplate = `<html>
+  <head>
+    <title>Index of %s</title>
+  </head>
+  <body>
+    <h1>Index of %s</h1>
+    <ul>
+%s    </ul>
+  </body>
+</html>
+`
+
+func writeListing(w io.Writer, r *request.Request, urlPath string, entries []fs.DirEntry) {
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	var items strings.Builder
+	if urlPath != "/" {
+		items.WriteString("      <li><a href=\"../\">../</a></li>\n")
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		href := (&url.URL{Path: name}).EscapedPath()
+		// a colon in the first segment would read as a scheme
+		if strings.Contains(strings.SplitN(name, "/", 2)[0], ":") {
+			href = "./" + href
+		}
+		fmt.Fprintf(&items, "      <li><a href=\"%s\">%s</a></li>\n", html.EscapeString(href), html.EscapeString(name))
+	}
+
+	title := html.EscapeString(urlPath)
+	body := []byte(fmt.Sprintf(listingTemplate, title, title, items.String()))
+
+	// listings have no modification time, so the content is the validator
+	etag := response.ContentETag(body)
+	if response.CheckPreconditions(r.RequestLine.Method, r.Headers, etag, time.Time{}) == response.NOT_MODIFIED {
+		writeNotModified(w, etag, "")
+		return
+	}
+
+	rw := response.NewWriter(w)
+	if err := rw.WriteStatusLine(response.OK); err != nil {
+		fmt.Printf("Write status line error: %v\n", err)
+		return
+	}
+
+	h := headers.NewHeaders()
+	h.SetDefault(len(body), map[string]string{
+		"content-type": "text/html; charset=utf-8",
+		"etag":         etag,
+	})
+	if err := rw.WriteHeaders(h); err != nil {
+		fmt.Printf("Write headers error: %v\n", err)
+		return
+	}
+
+	if r.RequestLine.Method == "HEAD" {
+		return
+	}
+
+	if _, err := rw.WriteBody(body); err != nil {
+		fmt.Printf("Write body error: %v\n", err)
+	}
+}
+
+func allowedMethod(w io.Writer, r *request.Request) bool {
+	switch r.RequestLine.Method {
+	case "GET", "HEAD":
+		return true
+	}
+
+	response.WriteErrorHeaders(w, response.METHOD_NOT_ALLOWED, map[string]string{
+		"allow": "GET, HEAD",
+	}, ErrMethodNotAllowed)
+	return false
+}
+
+func redirect(w io.Writer, location string) {
+	rw := response.NewWriter(w)
+	if err := rw.WriteStatusLine(response.MOVED_PERMANENTLY); err != nil {
+		fmt.Printf("Write status line error: %v\n", err)
+		return
+	}
+
+	h := headers.NewHeaders()
+	h.SetDefault(0, map[string]string{
+		"location": location,
+	})
+	if err := rw.WriteHeaders(h); err != nil {
+		fmt.Printf("Write headers error: %v\n", err)
+	}
+}
+
+func writeOpenError(w io.Writer, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		response.WriteError(w, response.NOT_FOUND, ErrNotFound)
+	default:
+		// permission problems and paths escaping the root look the same
+		response.WriteError(w, response.FORBIDDEN, ErrForbidden)
+	}
+}
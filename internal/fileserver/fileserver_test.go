@@ -0,0 +1,301 @@
+package fileserver
+
+import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *FileServer {
+	t.Helper()
+
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	files := map[string]string{
+		"root/hello.css":          "hello world",
+		"root/noext":              "<!DOCTYPE html><p>hi</p>",
+		"root/site/index.html":    "<h1>index</h1>",
+		"root/docs/a & b.js":      "var a",
+		"root/docs/sub/file.bin":  "\x00\x01\x02",
+		"secret.txt":              "top secret",
+		"root/docs/sub/empty.txt": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "escape.txt")))
+
+	s, err := NewFileServer(root)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func newRequest(method string, target string) *request.Request {
+	return &request.Request{
+		RequestLine: request.RequestLine{
+			Method:        method,
+			RequestTarget: target,
+			HttpVersion:   "1.1",
+		},
+		Headers: headers.NewHeaders(),
+	}
+}
+
+func do(t *testing.T, handle func(*bytes.Buffer, *request.Request), method string, target string) *response.Response {
+	t.Helper()
+
+	var buf bytes.Buffer
+	r := newRequest(method, target)
+	handle(&buf, r)
+
+	res, err := response.NewReader(&buf).ReadResponse(method)
+	require.NoError(t, err)
+	return res
+}
+
+func TestFileServer(t *testing.T) {
+	s := newTestServer(t)
+	s.StripPrefix = "/static"
+	handle := func(buf *bytes.Buffer, r *request.Request) { s.Handle(buf, r) }
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		status      response.StatusCode
+		contentType string
+		body        string
+		location    string
+	}{
+		{
+			name:        "File by extension",
+			target:      "/static/hello.css?x=1",
+			status:      response.OK,
+			contentType: "text/css; charset=utf-8",
+			body:        "hello world",
+		},
+		{
+			name:        "Sniffed file",
+			target:      "/static/noext",
+			status:      response.OK,
+			contentType: "text/html; charset=utf-8",
+			body:        "<!DOCTYPE html><p>hi</p>",
+		},
+		{
+			name:        "Escaped name",
+			target:      "/static/docs/a%20%26%20b.js",
+			status:      response.OK,
+			contentType: "text/javascript; charset=utf-8",
+			body:        "var a",
+		},
+		{
+			name:        "Head",
+			method:      "HEAD",
+			target:      "/static/hello.css",
+			status:      response.OK,
+			contentType: "text/css; charset=utf-8",
+		},
+		{
+			name:        "Index",
+			target:      "/static/site/",
+			status:      response.OK,
+			contentType: "text/html; charset=utf-8",
+			body:        "<h1>index</h1>",
+		},
+		{
+			name:     "Directory redirect",
+			target:   "/static/site",
+			status:   response.MOVED_PERMANENTLY,
+			location: "/static/site/",
+		},
+		{
+			name:   "Listing disabled",
+			target: "/static/docs/",
+			status: response.FORBIDDEN,
+		},
+		{
+			name:   "Missing file",
+			target: "/static/nope.txt",
+			status: response.NOT_FOUND,
+		},
+		{
+			name:   "Outside prefix",
+			target: "/other/hello.css",
+			status: response.NOT_FOUND,
+		},
+		{
+			name:   "Prefix without segment boundary",
+			target: "/statichello.css",
+			status: response.NOT_FOUND,
+		},
+		{
+			name:   "Dot dot",
+			target: "/static/../secret.txt",
+			status: response.NOT_FOUND,
+		},
+		{
+			name:   "Encoded dot dot",
+			target: "/static/%2e%2e/%2e%2e/secret.txt",
+			status: response.NOT_FOUND,
+		},
+		{
+			name:   "Symlink out of root",
+			target: "/static/escape.txt",
+			status: response.FORBIDDEN,
+		},
+		{
+			name:   "NUL byte",
+			target: "/static/hello.css%00.png",
+			status: response.NOT_FOUND,
+		},
+		{
+			name:   "Wrong method",
+			method: "POST",
+			target: "/static/hello.css",
+			status: response.METHOD_NOT_ALLOWED,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = "GET"
+			}
+
+			res := do(t, handle, method, tc.target)
+			assert.Equal(t, tc.status, res.StatusLine.StatusCode)
+			if tc.status != response.OK {
+				assert.Equal(t, tc.location, res.Headers["location"])
+				return
+			}
+			assert.Equal(t, tc.contentType, res.Headers["content-type"])
+			assert.Equal(t, tc.body, string(res.Body))
+		})
+	}
+}
+
+func TestFileServerHeadContentLength(t *testing.T) {
+	s := newTestServer(t)
+	handle := func(buf *bytes.Buffer, r *request.Request) { s.Handle(buf, r) }
+
+	res := do(t, handle, "HEAD", "/hello.css")
+	assert.Equal(t, "11", res.Headers["content-length"])
+	assert.Empty(t, res.Body)
+}
+
+func TestFileServerListing(t *testing.T) {
+	s := newTestServer(t)
+	s.Listing = true
+	handle := func(buf *bytes.Buffer, r *request.Request) { s.Handle(buf, r) }
+
+	res := do(t, handle, "GET", "/docs/")
+	require.Equal(t, response.OK, res.StatusLine.StatusCode)
+
+	body := string(res.Body)
+	assert.Contains(t, body, "<title>Index of /docs/</title>")
+	assert.Contains(t, body, `<li><a href="../">../</a></li>`)
+	assert.Contains(t, body, `<li><a href="a%20&amp;%20b.js">a &amp; b.js</a></li>`)
+	assert.Contains(t, body, `<li><a href="sub/">sub/</a></li>`)
+
+	r := newRequest("GET", "/docs/")
+	r.Headers["if-none-match"] = res.Headers["etag"]
+	var buf bytes.Buffer
+	s.Handle(&buf, r)
+	res, err := response.ResponseFromReader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, response.NOT_MODIFIED, res.StatusLine.StatusCode)
+}
+
+func TestServeFile(t *testing.T) {
+	s := newTestServer(t)
+
+	res := do(t, func(buf *bytes.Buffer, r *request.Request) {
+		s.ServeFile(buf, r, "hello.css")
+	}, "GET", "/video")
+	assert.Equal(t, response.OK, res.StatusLine.StatusCode)
+	assert.Equal(t, "hello world", string(res.Body))
+
+	res = do(t, func(buf *bytes.Buffer, r *request.Request) {
+		s.ServeFile(buf, r, "../secret.txt")
+	}, "GET", "/video")
+	assert.Equal(t, response.NOT_FOUND, res.StatusLine.StatusCode)
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"Empty", "", "text/plain; charset=utf-8"},
+		{"Text", "just some text\n", "text/plain; charset=utf-8"},
+		{"Cut rune", "caf\xc3", "text/plain; charset=utf-8"},
+		{"HTML", "\n  <HTML><body>", "text/html; charset=utf-8"},
+		{"PNG", "\x89PNG\r\n\x1a\n\x00\x00", "image/png"},
+		{"MP4", "\x00\x00\x00\x18ftypmp42", "video/mp4"},
+		{"PDF", "%PDF-1.7", "application/pdf"},
+		{"Binary", "\x00\x01\x02\x03", "application/octet-stream"},
+		{"Invalid UTF-8", "\xff\xfeabc", "application/octet-stream"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DetectContentType([]byte(tc.data)))
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	s, modTime := newRangeServer(t)
+	etag := response.FileETag(10, modTime, false)
+	lastModified := modTime.Format(response.TimeFormat)
+	before := modTime.Add(-time.Hour).Format(response.TimeFormat)
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		status response.StatusCode
+	}{
+		{"If-None-Match matches", map[string]string{"if-none-match": `"x", W/` + etag}, response.NOT_MODIFIED},
+		{"If-None-Match differs", map[string]string{"if-none-match": `"x"`}, response.OK},
+		{"If-None-Match wildcard", map[string]string{"if-none-match": "*"}, response.NOT_MODIFIED},
+		{"If-Modified-Since not modified", map[string]string{"if-modified-since": lastModified}, response.NOT_MODIFIED},
+		{"If-Modified-Since modified", map[string]string{"if-modified-since": before}, response.OK},
+		{"If-None-Match wins over If-Modified-Since", map[string]string{"if-none-match": `"x"`, "if-modified-since": lastModified}, response.OK},
+		{"If-Match matches", map[string]string{"if-match": etag}, response.OK},
+		{"If-Match differs", map[string]string{"if-match": `"x"`}, response.PRECONDITION_FAILED},
+		{"If-Match weak", map[string]string{"if-match": "W/" + etag}, response.PRECONDITION_FAILED},
+		{"If-Unmodified-Since passes", map[string]string{"if-unmodified-since": lastModified}, response.OK},
+		{"If-Unmodified-Since fails", map[string]string{"if-unmodified-since": before}, response.PRECONDITION_FAILED},
+		{"If-Match wins over If-Unmodified-Since", map[string]string{"if-match": etag, "if-unmodified-since": before}, response.OK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := get(t, s, tc.fields)
+			assert.Equal(t, tc.status, res.StatusLine.StatusCode)
+			if tc.status == response.PRECONDITION_FAILED {
+				return
+			}
+			assert.Equal(t, etag, res.Headers["etag"])
+			assert.Equal(t, lastModified, res.Headers["last-modified"])
+			if tc.status == response.NOT_MODIFIED {
+				assert.Empty(t, res.Body)
+				assert.NotContains(t, res.Headers, "content-length")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package fileserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/response"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrWrongRange         = errors.New("malformed range")
+	ErrUnsatisfiableRange = errors.New("no satisfiable range")
+)
+
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+func (br ByteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", br.Start, br.Start+br.Length-1, size)
+}
+
+// ParseRange parses a Range header against a representation of size bytes.
+// Ranges starting past the end are dropped and the rest are clipped to the
+// size; ErrUnsatisfiableRange is returned when nothing is left. Any syntax
+// error invalidates the whole header with ErrWrongRange, in which case the
+// header should be ignored.
+func ParseRange(value string, size int64) ([]ByteRange, error) {
+	unit, specs, ok := strings.Cut(value, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, ErrWrongRange
+	}
+
+	var ranges []ByteRange
+	seen := false
+
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		seen = true
+
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, ErrWrongRange
+		}
+
+		// suffix range: the last n bytes
+		if first == "" {
+			n, err := parseDigits(last)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			ranges = append(ranges, ByteRange{Start: size - n, Length: n})
+			continue
+		}
+
+		start, err := parseDigits(first)
+		if err != nil {
+			return nil, err
+		}
+
+		end := size - 1
+		if last != "" {
+			end, err = parseDigits(last)
+			if err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, ErrWrongRange
+			}
+			end = min(end, size-1)
+		}
+
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, ByteRange{Start: start, Length: end - start + 1})
+	}
+
+	if !seen {
+		return nil, ErrWrongRange
+	}
+	if len(ranges) == 0 {
+		return nil, ErrUnsatisfiableRange
+	}
+
+	return ranges, nil
+}
+
+func parseDigits(s string) (int64, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, ErrWrongRange
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrWrongRange
+	}
+	return n, nil
+}
+
+func totalLength(ranges []ByteRange) int64 {
+	var total int64
+	for _, br := range ranges {
+		total += br.Length
+	}
+	return total
+}
+
+// multipartRanges lays out a multipart/byteranges body so its length is
+// known before any of it is written.
+type multipartRanges struct {
+	boundary string
+	heads    []string
+	tail     string
+	length   int64
+}
+
+func newMultipartRanges(ranges []ByteRange, contentType string, size int64) multipartRanges {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+
+	m := multipartRanges{
+		boundary: hex.EncodeToString(nonce),
+	}
+
+	for i, br := range ranges {
+		head := fmt.Sprintf("--%s\r\ncontent-type: %s\r\ncontent-range: %s\r\n\r\n",
+			m.boundary, contentType, br.contentRange(size))
+		if i > 0 {
+			head = "\r\n" + head
+		}
+		m.heads = append(m.heads, head)
+		m.length += int64(len(head)) + br.Length
+	}
+
+	m.tail = "\r\n--" + m.boundary + "--\r\n"
+	m.length += int64(len(m.tail))
+
+	return m
+}
+
+func (m multipartRanges) contentType() string {
+	return "multipart/byteranges; boundary=" + m.boundary
+}
+
+// write copies every range of f between the part headers.
+func (m multipartRanges) write(rw *response.Writer, f io.ReadSeeker, ranges []ByteRange) error {
+	for i, br := range ranges {
+		if _, err := rw.WriteBody([]byte(m.heads[i])); err != nil {
+			return err
+		}
+		if err := writeRange(rw, f, br); err != nil {
+			return err
+		}
+	}
+
+	_, err := rw.WriteBody([]byte(m.tail))
+	return err
+}
+
+func writeRange(rw *response.Writer, f io.ReadSeeker, br ByteRange) error {
+	if _, err := f.Seek(br.Start, io.SeekStart); err != nil {
+		return err
+	}
+
+	n, err := rw.WriteBodyFrom(io.LimitReader(f, br.Length))
+	if err == nil && n < br.Length {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,288 @@
+package fileserver
+
+import (
+	"bytes"
+	"httpfromtcp/internal/response"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		size     int64
+		expected []ByteRange
+		err      error
+	}{
+		{
+			name:     "Single range",
+			value:    "bytes=0-4",
+			size:     10,
+			expected: []ByteRange{{0, 5}},
+		},
+		{
+			name:     "Open ended",
+			value:    "bytes=7-",
+			size:     10,
+			expected: []ByteRange{{7, 3}},
+		},
+		{
+			name:     "Suffix",
+			value:    "bytes=-3",
+			size:     10,
+			expected: []ByteRange{{7, 3}},
+		},
+		{
+			name:     "Suffix longer than file",
+			value:    "bytes=-30",
+			size:     10,
+			expected: []ByteRange{{0, 10}},
+		},
+		{
+			name:     "End clipped",
+			value:    "bytes=5-100",
+			size:     10,
+			expected: []ByteRange{{5, 5}},
+		},
+		{
+			name:     "Multiple with spaces and empty elements",
+			value:    "Bytes= 0-1 , ,4-5,-1",
+			size:     10,
+			expected: []ByteRange{{0, 2}, {4, 2}, {9, 1}},
+		},
+		{
+			name:     "Unsatisfiable ranges dropped",
+			value:    "bytes=20-30, 2-3",
+			size:     10,
+			expected: []ByteRange{{2, 2}},
+		},
+		{
+			name:  "Nothing satisfiable",
+			value: "bytes=10-, -0",
+			size:  10,
+			err:   ErrUnsatisfiableRange,
+		},
+		{
+			name:  "Empty file",
+			value: "bytes=0-",
+			size:  0,
+			err:   ErrUnsatisfiableRange,
+		},
+		{
+			name:  "Other unit",
+			value: "items=0-1",
+			size:  10,
+			err:   ErrWrongRange,
+		},
+		{
+			name:  "Reversed",
+			value: "bytes=5-1",
+			size:  10,
+			err:   ErrWrongRange,
+		},
+		{
+			name:  "Sign",
+			value: "bytes=+1-2",
+			size:  10,
+			err:   ErrWrongRange,
+		},
+		{
+			name:  "No dash",
+			value: "bytes=5",
+			size:  10,
+			err:   ErrWrongRange,
+		},
+		{
+			name:  "No specs",
+			value: "bytes=,",
+			size:  10,
+			err:   ErrWrongRange,
+		},
+		{
+			name:  "Overflow",
+			value: "bytes=0-99999999999999999999",
+			size:  10,
+			err:   ErrWrongRange,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges, err := ParseRange(tc.value, tc.size)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ranges)
+		})
+	}
+}
+
+func newRangeServer(t *testing.T) (*FileServer, time.Time) {
+	t.Helper()
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "digits.txt")
+	require.NoError(t, os.WriteFile(p, []byte("0123456789"), 0o644))
+
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(p, modTime, modTime))
+
+	s, err := NewFileServer(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return s, modTime
+}
+
+func get(t *testing.T, s *FileServer, fields map[string]string) *response.Response {
+	t.Helper()
+
+	r := newRequest("GET", "/digits.txt")
+	for k, v := range fields {
+		r.Headers[k] = v
+	}
+
+	var buf bytes.Buffer
+	s.Handle(&buf, r)
+
+	res, err := response.ResponseFromReader(&buf)
+	require.NoError(t, err)
+	return res
+}
+
+func TestRangeRequests(t *testing.T) {
+	s, modTime := newRangeServer(t)
+	lastModified := modTime.Format(response.TimeFormat)
+
+	tests := []struct {
+		name         string
+		fields       map[string]string
+		status       response.StatusCode
+		contentRange string
+		body         string
+	}{
+		{
+			name:   "No range",
+			status: response.OK,
+			body:   "0123456789",
+		},
+		{
+			name:         "Single range",
+			fields:       map[string]string{"range": "bytes=2-4"},
+			status:       response.PARTIAL_CONTENT,
+			contentRange: "bytes 2-4/10",
+			body:         "234",
+		},
+		{
+			name:         "Suffix range",
+			fields:       map[string]string{"range": "bytes=-2"},
+			status:       response.PARTIAL_CONTENT,
+			contentRange: "bytes 8-9/10",
+			body:         "89",
+		},
+		{
+			name:         "Unsatisfiable",
+			fields:       map[string]string{"range": "bytes=10-"},
+			status:       response.RANGE_NOT_SATISFIABLE,
+			contentRange: "bytes */10",
+		},
+		{
+			name:   "Malformed range ignored",
+			fields: map[string]string{"range": "bytes=x-y"},
+			status: response.OK,
+			body:   "0123456789",
+		},
+		{
+			name:   "Ranges larger than file ignored",
+			fields: map[string]string{"range": "bytes=0-9,0-9"},
+			status: response.OK,
+			body:   "0123456789",
+		},
+		{
+			name:         "If-Range date matches",
+			fields:       map[string]string{"range": "bytes=0-0", "if-range": lastModified},
+			status:       response.PARTIAL_CONTENT,
+			contentRange: "bytes 0-0/10",
+			body:         "0",
+		},
+		{
+			name:   "If-Range date changed",
+			fields: map[string]string{"range": "bytes=0-0", "if-range": modTime.Add(-time.Hour).Format(response.TimeFormat)},
+			status: response.OK,
+			body:   "0123456789",
+		},
+		{
+			name:         "If-Range current etag",
+			fields:       map[string]string{"range": "bytes=1-1", "if-range": response.FileETag(10, modTime, false)},
+			status:       response.PARTIAL_CONTENT,
+			contentRange: "bytes 1-1/10",
+			body:         "1",
+		},
+		{
+			name:   "If-Range unknown etag",
+			fields: map[string]string{"range": "bytes=0-0", "if-range": `"abc"`},
+			status: response.OK,
+			body:   "0123456789",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := get(t, s, tc.fields)
+			assert.Equal(t, tc.status, res.StatusLine.StatusCode)
+			assert.Equal(t, tc.contentRange, res.Headers["content-range"])
+			if tc.status == response.RANGE_NOT_SATISFIABLE {
+				return
+			}
+			assert.Equal(t, "bytes", res.Headers["accept-ranges"])
+			assert.Equal(t, lastModified, res.Headers["last-modified"])
+			assert.Equal(t, tc.body, string(res.Body))
+		})
+	}
+}
+
+func TestMultipleRanges(t *testing.T) {
+	s, _ := newRangeServer(t)
+
+	res := get(t, s, map[string]string{"range": "bytes=0-1,5-6,-1"})
+	require.Equal(t, response.PARTIAL_CONTENT, res.StatusLine.StatusCode)
+
+	mediaType, params, err := mime.ParseMediaType(res.Headers["content-type"])
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mediaType)
+
+	expected := []struct {
+		contentRange string
+		body         string
+	}{
+		{"bytes 0-1/10", "01"},
+		{"bytes 5-6/10", "56"},
+		{"bytes 9-9/10", "9"},
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(res.Body), params["boundary"])
+	for _, e := range expected {
+		part, err := mr.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, e.contentRange, part.Header.Get("Content-Range"))
+		assert.Equal(t, "text/plain; charset=utf-8", part.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Equal(t, e.body, string(body))
+	}
+
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+}
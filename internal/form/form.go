@@ -0,0 +1,232 @@
+package form
+
+import (
+	"bytes"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"io"
+	"net/url"
+	"os"
+)
+
+const (
+	defaultMaxMemory   = 10 << 20
+	defaultMaxParts    = 1000
+	defaultMaxPartSize = 32 << 20
+)
+
+var (
+	ErrNotForm             = errors.New("request body is not a form")
+	ErrMissingBoundary     = errors.New("multipart form without boundary")
+	ErrTooManyParts        = errors.New("too many form parts")
+	ErrPartTooLarge        = errors.New("form part too large")
+	ErrValuesTooLarge      = errors.New("form values too large")
+	ErrWrongURLEncodedBody = errors.New("malformed urlencoded form")
+)
+
+// Parser reads application/x-www-form-urlencoded and multipart/form-data
+// request bodies.
+type Parser struct {
+	// MaxMemory is how much of a multipart form is kept in memory. Plain
+	// values have to fit; file contents beyond it are spooled to temporary
+	// files.
+	MaxMemory int64
+	// MaxParts limits the number of fields or parts.
+	MaxParts int
+	// MaxPartSize limits each part's content, files included.
+	MaxPartSize int64
+	// TempDir holds spooled files; empty means os.TempDir.
+	TempDir string
+}
+
+func NewParser() *Parser {
+	return &Parser{
+		MaxMemory:   defaultMaxMemory,
+		MaxParts:    defaultMaxParts,
+		MaxPartSize: defaultMaxPartSize,
+	}
+}
+
+// Form is a parsed form. Call RemoveAll once done with it to delete the
+// spooled files.
+type Form struct {
+	Values url.Values
+	Files  map[string][]*File
+}
+
+// File is an uploaded file, held in memory or in a temporary file.
+type File struct {
+	FileName string
+	Headers  headers.Headers
+	Size     int64
+
+	content []byte
+	tmpPath string
+}
+
+func (f *File) Open() (io.ReadCloser, error) {
+	if f.tmpPath != "" {
+		return os.Open(f.tmpPath)
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+func (f *Form) RemoveAll() error {
+	var errs []error
+	for _, files := range f.Files {
+		for _, file := range files {
+			if file.tmpPath == "" {
+				continue
+			}
+			if err := os.Remove(file.tmpPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Parse reads the form in r's body according to its Content-Type.
+func (p *Parser) Parse(r *request.Request) (*Form, error) {
+	mediaType, params, err := r.Headers.MediaType()
+	if err != nil {
+		return nil, ErrNotForm
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := p.ParseURLEncoded(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &Form{Values: values, Files: map[string][]*File{}}, nil
+	case "multipart/form-data":
+		boundary := params["boundary"]
+		if boundary == "" {
+			return nil, ErrMissingBoundary
+		}
+		return p.ParseMultipart(bytes.NewReader(r.Body), boundary)
+	}
+
+	return nil, ErrNotForm
+}
+
+func (p *Parser) ParseURLEncoded(body []byte) (url.Values, error) {
+	if p.MaxParts > 0 && bytes.Count(body, []byte("&"))+1 > p.MaxParts {
+		return nil, ErrTooManyParts
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, ErrWrongURLEncodedBody
+	}
+	return values, nil
+}
+
+// ParseMultipart reads a multipart/form-data body from r as it arrives.
+func (p *Parser) ParseMultipart(r io.Reader, boundary string) (*Form, error) {
+	form := &Form{
+		Values: url.Values{},
+		Files:  map[string][]*File{},
+	}
+	memoryLeft := p.MaxMemory
+
+	mr := NewMultipartReader(r, boundary)
+	for parts := 0; ; parts++ {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		}
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+
+		if p.MaxParts > 0 && parts >= p.MaxParts {
+			form.RemoveAll()
+			return nil, ErrTooManyParts
+		}
+
+		var src io.Reader = part
+		if p.MaxPartSize > 0 {
+			src = &limitedPart{part, p.MaxPartSize}
+		}
+
+		name := part.FormName()
+		if !part.isFile() {
+			value, err := io.ReadAll(io.LimitReader(src, memoryLeft+1))
+			if err == nil && int64(len(value)) > memoryLeft {
+				err = ErrValuesTooLarge
+			}
+			if err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+
+			memoryLeft -= int64(len(value))
+			form.Values.Add(name, string(value))
+			continue
+		}
+
+		file, err := p.readFile(part, src, memoryLeft)
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+		if file.tmpPath == "" {
+			memoryLeft -= file.Size
+		}
+		form.Files[name] = append(form.Files[name], file)
+	}
+}
+
+// readFile keeps the content in memory when it fits in memoryLeft and
+// writes it to a temporary file otherwise.
+func (p *Parser) readFile(part *Part, src io.Reader, memoryLeft int64) (*File, error) {
+	file := &File{
+		FileName: part.FileName(),
+		Headers:  part.Headers,
+	}
+
+	content, err := io.ReadAll(io.LimitReader(src, max(memoryLeft, 0)+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) <= memoryLeft {
+		file.content = content
+		file.Size = int64(len(content))
+		return file, nil
+	}
+
+	tmp, err := os.CreateTemp(p.TempDir, "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(content), src))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	file.tmpPath = tmp.Name()
+	file.Size = size
+	return file, nil
+}
+
+// limitedPart fails once a part goes past max bytes.
+type limitedPart struct {
+	r   io.Reader
+	max int64
+}
+
+func (l *limitedPart) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.max -= int64(n)
+	if l.max < 0 {
+		return n, ErrPartTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,153 @@
+package form
+
+import (
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formRequest(contentType string, body string) *request.Request {
+	h := headers.NewHeaders()
+	if contentType != "" {
+		h["content-type"] = contentType
+	}
+	return &request.Request{
+		RequestLine: request.RequestLine{Method: "POST", RequestTarget: "/", HttpVersion: "1.1"},
+		Headers:     h,
+		Body:        []byte(body),
+	}
+}
+
+func readFile(t *testing.T, f *File) string {
+	t.Helper()
+
+	rc, err := f.Open()
+	require.NoError(t, err)
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestParseURLEncoded(t *testing.T) {
+	r := formRequest("application/x-www-form-urlencoded; charset=utf-8", "name=Ada+Lovelace&tag=a&tag=b%26c&empty=")
+
+	form, err := NewParser().Parse(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada Lovelace", form.Values.Get("name"))
+	assert.Equal(t, []string{"a", "b&c"}, form.Values["tag"])
+	assert.Equal(t, "", form.Values.Get("empty"))
+	assert.Empty(t, form.Files)
+
+	_, err = NewParser().Parse(formRequest("application/x-www-form-urlencoded", "bad=%zz"))
+	assert.ErrorIs(t, err, ErrWrongURLEncodedBody)
+
+	p := NewParser()
+	p.MaxParts = 2
+	_, err = p.Parse(formRequest("application/x-www-form-urlencoded", "a=1&b=2&c=3"))
+	assert.ErrorIs(t, err, ErrTooManyParts)
+}
+
+func TestParseMultipart(t *testing.T) {
+	small := "tiny file"
+	large := strings.Repeat("0123456789", 1000)
+	body, boundary := multipartBody(t,
+		testPart{name: "title", content: "hello"},
+		testPart{name: "doc", fileName: "small.txt", content: small},
+		testPart{name: "doc", fileName: `C:\Users\ada\large.bin`, content: large},
+	)
+
+	p := NewParser()
+	p.MaxMemory = 1024
+	p.TempDir = t.TempDir()
+
+	form, err := p.Parse(formRequest("multipart/form-data; boundary="+boundary, body))
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", form.Values.Get("title"))
+	require.Len(t, form.Files["doc"], 2)
+
+	smallFile, largeFile := form.Files["doc"][0], form.Files["doc"][1]
+	assert.Equal(t, "small.txt", smallFile.FileName)
+	assert.Equal(t, int64(len(small)), smallFile.Size)
+	assert.Equal(t, small, readFile(t, smallFile))
+	assert.Empty(t, smallFile.tmpPath)
+
+	assert.Equal(t, "large.bin", largeFile.FileName)
+	assert.Equal(t, int64(len(large)), largeFile.Size)
+	assert.Equal(t, "application/octet-stream", largeFile.Headers["content-type"])
+	assert.Equal(t, large, readFile(t, largeFile))
+	require.NotEmpty(t, largeFile.tmpPath)
+
+	require.NoError(t, form.RemoveAll())
+	_, err = os.Stat(largeFile.tmpPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestParseMultipartLimits(t *testing.T) {
+	body, boundary := multipartBody(t,
+		testPart{name: "a", content: "1"},
+		testPart{name: "b", content: strings.Repeat("x", 2000)},
+		testPart{name: "c", fileName: "c.bin", content: strings.Repeat("y", 5000)},
+	)
+	contentType := "multipart/form-data; boundary=" + boundary
+
+	tests := []struct {
+		name   string
+		parser func(p *Parser)
+		err    error
+	}{
+		{"Too many parts", func(p *Parser) { p.MaxParts = 2 }, ErrTooManyParts},
+		{"Part too large", func(p *Parser) { p.MaxPartSize = 4000 }, ErrPartTooLarge},
+		{"Values too large", func(p *Parser) { p.MaxMemory = 1000 }, ErrValuesTooLarge},
+		{"Within limits", func(p *Parser) { p.MaxParts = 3; p.MaxPartSize = 5000; p.MaxMemory = 2001 }, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			p := NewParser()
+			p.TempDir = dir
+			tc.parser(p)
+
+			form, err := p.Parse(formRequest(contentType, body))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				// nothing spooled is left behind
+				entries, _ := os.ReadDir(dir)
+				assert.Empty(t, entries)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, strings.Repeat("y", 5000), readFile(t, form.Files["c"][0]))
+			require.NoError(t, form.RemoveAll())
+		})
+	}
+}
+
+func TestParseNotForm(t *testing.T) {
+	tests := []struct {
+		contentType string
+		err         error
+	}{
+		{"", ErrNotForm},
+		{"application/json", ErrNotForm},
+		{"multipart/form-data", ErrMissingBoundary},
+		{"not a media type", ErrNotForm},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.contentType, func(t *testing.T) {
+			_, err := NewParser().Parse(formRequest(tc.contentType, "a=1"))
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package form
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"io"
+	"strings"
+)
+
+const (
+	maxPartHeaderBytes = 16 << 10
+	readerBufferSize   = 32 << 10
+)
+
+var (
+	ErrWrongMultipart      = errors.New("malformed multipart body")
+	ErrPartHeadersTooLarge = errors.New("part headers too large")
+)
+
+// MultipartReader reads a multipart body part by part without holding it
+// in memory.
+type MultipartReader struct {
+	br             *bufio.Reader
+	dashBoundary   []byte
+	nlDashBoundary []byte
+
+	part    *Part
+	started bool
+	done    bool
+}
+
+func NewMultipartReader(r io.Reader, boundary string) *MultipartReader {
+	return &MultipartReader{
+		br:             bufio.NewReaderSize(r, readerBufferSize),
+		dashBoundary:   []byte("--" + boundary),
+		nlDashBoundary: []byte("\r\n--" + boundary),
+	}
+}
+
+// Part is one body part. Reading it yields the part's content; it is
+// skipped by the next call to NextPart.
+type Part struct {
+	Headers headers.Headers
+
+	mr  *MultipartReader
+	eof bool
+	err error
+}
+
+// NextPart returns the next part, or io.EOF after the last one.
+func (mr *MultipartReader) NextPart() (*Part, error) {
+	if mr.done {
+		return nil, io.EOF
+	}
+
+	if mr.part != nil {
+		if _, err := io.Copy(io.Discard, mr.part); err != nil {
+			return nil, err
+		}
+		mr.part = nil
+	}
+
+	var err error
+	if !mr.started {
+		mr.started = true
+		err = mr.skipPreamble()
+	} else {
+		err = mr.readDelimiterLine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if mr.done {
+		return nil, io.EOF
+	}
+
+	h, err := mr.readPartHeaders()
+	if err != nil {
+		return nil, err
+	}
+
+	mr.part = &Part{Headers: h, mr: mr}
+	return mr.part, nil
+}
+
+// skipPreamble discards lines up to the first delimiter.
+func (mr *MultipartReader) skipPreamble() error {
+	for {
+		line, err := mr.br.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return ErrWrongMultipart
+		}
+		if err == nil && bytes.HasPrefix(line, mr.dashBoundary) {
+			return mr.endDelimiterLine(line[len(mr.dashBoundary):])
+		}
+	}
+}
+
+// readDelimiterLine consumes the delimiter a part's content stopped at.
+func (mr *MultipartReader) readDelimiterLine() error {
+	if _, err := mr.br.Discard(len(mr.nlDashBoundary)); err != nil {
+		return ErrWrongMultipart
+	}
+
+	line, err := mr.br.ReadSlice('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && bytes.HasPrefix(line, []byte("--"))) {
+		return ErrWrongMultipart
+	}
+	return mr.endDelimiterLine(line)
+}
+
+// endDelimiterLine checks what follows a boundary: "--" for the last one,
+// otherwise optional whitespace and CRLF.
+func (mr *MultipartReader) endDelimiterLine(rest []byte) error {
+	if bytes.HasPrefix(rest, []byte("--")) {
+		mr.done = true
+		return nil
+	}
+
+	if strings.TrimLeft(string(rest), " \t") != "\r\n" {
+		return ErrWrongMultipart
+	}
+	return nil
+}
+
+func (mr *MultipartReader) readPartHeaders() (headers.Headers, error) {
+	h := headers.NewHeaders()
+	var data []byte
+
+	for {
+		line, err := mr.br.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				return nil, ErrPartHeadersTooLarge
+			}
+			return nil, ErrWrongMultipart
+		}
+
+		data = append(data, line...)
+		if len(data) > maxPartHeaderBytes {
+			return nil, ErrPartHeadersTooLarge
+		}
+
+		n, done, err := h.Parse(data)
+		if err != nil {
+			return nil, ErrWrongMultipart
+		}
+		if done {
+			return h, nil
+		}
+		data = data[n:]
+	}
+}
+
+// Read stops at the CRLF before the next delimiter, which is left for
+// NextPart.
+func (p *Part) Read(b []byte) (int, error) {
+	if p.eof {
+		return 0, io.EOF
+	}
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	br := p.mr.br
+	// make sure a whole delimiter and what follows it would be visible
+	want := max(br.Buffered(), len(p.mr.nlDashBoundary)+2)
+
+	for {
+		buf, err := br.Peek(min(want, br.Size()))
+		atEOF := err != nil
+
+		end, found := p.mr.scan(buf, atEOF)
+		if found {
+			n := copy(b, buf[:end])
+			br.Discard(n)
+			if n == end {
+				p.eof = true
+				if n == 0 {
+					return 0, io.EOF
+				}
+			}
+			return n, nil
+		}
+
+		if atEOF {
+			// the body ended before the closing delimiter
+			p.err = ErrWrongMultipart
+			return 0, p.err
+		}
+
+		if end == 0 {
+			// a possible delimiter needs more bytes to decide
+			if len(buf) >= br.Size() {
+				p.err = ErrWrongMultipart
+				return 0, p.err
+			}
+			want = len(buf) + 1
+			continue
+		}
+
+		n := copy(b, buf[:end])
+		br.Discard(n)
+		return n, nil
+	}
+}
+
+// scan returns how much of buf is part content for sure. found reports
+// that a delimiter follows right after it.
+func (mr *MultipartReader) scan(buf []byte, atEOF bool) (int, bool) {
+	delim := mr.nlDashBoundary
+
+	for from := 0; ; {
+		i := bytes.Index(buf[from:], delim)
+		if i < 0 {
+			break
+		}
+		i += from
+
+		if mr.delimiterAt(buf[i:]) {
+			return i, true
+		}
+		if !atEOF && delimiterPrefix(buf[i+len(delim):]) {
+			return i, false
+		}
+		from = i + 1
+	}
+
+	if atEOF {
+		return len(buf), false
+	}
+	// the tail could be the start of a delimiter
+	return max(len(buf)-len(delim)+1, 0), false
+}
+
+// delimiterAt reports whether buf starts with a delimiter: the boundary
+// followed by "--", or by optional whitespace and CRLF. Anything else, like
+// "--boundaryX", is part content.
+func (mr *MultipartReader) delimiterAt(buf []byte) bool {
+	if !bytes.HasPrefix(buf, mr.nlDashBoundary) {
+		return false
+	}
+
+	rest := buf[len(mr.nlDashBoundary):]
+	if bytes.HasPrefix(rest, []byte("--")) {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(rest, " \t"), []byte("\r\n"))
+}
+
+// delimiterPrefix reports whether what follows a boundary could still
+// become "--" or whitespace and CRLF with more bytes.
+func delimiterPrefix(rest []byte) bool {
+	if string(rest) == "-" {
+		return true
+	}
+	rest = bytes.TrimLeft(rest, " \t")
+	return len(rest) == 0 || string(rest) == "\r"
+}
+
+// FormName returns the name parameter of the part's Content-Disposition.
+func (p *Part) FormName() string {
+	_, params := p.disposition()
+	return params["name"]
+}
+
+// FileName returns the base name of the filename parameter, if any.
+func (p *Part) FileName() string {
+	_, params := p.disposition()
+	name := params["filename"]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// isFile reports whether the part is a file upload, even one without a
+// name.
+func (p *Part) isFile() bool {
+	_, params := p.disposition()
+	_, ok := params["filename"]
+	return ok
+}
+
+func (p *Part) disposition() (string, map[string]string) {
+	value, ok := p.Headers.Get("content-disposition")
+	if !ok {
+		return "", nil
+	}
+
+	disposition, params, err := headers.ParseParams(value)
+	if err != nil {
+		return "", nil
+	}
+	return disposition, params
+}
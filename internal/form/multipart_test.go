@@ -0,0 +1,160 @@
+package form
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPart struct {
+	name     string
+	fileName string
+	content  string
+}
+
+func multipartBody(t *testing.T, parts ...testPart) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		var w io.Writer
+		var err error
+		if p.fileName != "" {
+			w, err = mw.CreateFormFile(p.name, p.fileName)
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		require.NoError(t, err)
+		_, err = w.Write([]byte(p.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	return buf.String(), mw.Boundary()
+}
+
+func TestMultipartReader(t *testing.T) {
+	parts := []testPart{
+		{name: "title", content: "hello"},
+		{name: "empty"},
+		{name: "upload", fileName: "notes.txt", content: strings.Repeat("line\r\n--almost a boundary\r\n", 2000)},
+	}
+	body, boundary := multipartBody(t, parts...)
+
+	readers := map[string]func(string) io.Reader{
+		"Whole":    func(s string) io.Reader { return strings.NewReader(s) },
+		"One byte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+	}
+
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			mr := NewMultipartReader(newReader("preamble\r\n"+body+"epilogue"), boundary)
+
+			for _, expected := range parts {
+				part, err := mr.NextPart()
+				require.NoError(t, err)
+				assert.Equal(t, expected.name, part.FormName())
+				assert.Equal(t, expected.fileName, part.FileName())
+
+				content, err := io.ReadAll(part)
+				require.NoError(t, err)
+				assert.Equal(t, expected.content, string(content))
+			}
+
+			_, err := mr.NextPart()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestMultipartReaderSkipsUnreadParts(t *testing.T) {
+	body, boundary := multipartBody(t,
+		testPart{name: "a", content: strings.Repeat("x", 100000)},
+		testPart{name: "b", content: "second"},
+	)
+
+	mr := NewMultipartReader(strings.NewReader(body), boundary)
+	_, err := mr.NextPart()
+	require.NoError(t, err)
+
+	part, err := mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "b", part.FormName())
+
+	content, err := io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(content))
+}
+
+func TestMultipartReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{"No delimiter", "just text\r\n", ErrWrongMultipart},
+		{"Truncated part", "--b\r\ncontent-disposition: form-data; name=a\r\n\r\nvalue", ErrWrongMultipart},
+		{"Garbage after boundary", "--bx\r\n\r\nvalue\r\n--b--", ErrWrongMultipart},
+		{"Bad part header", "--b\r\nno colon\r\n\r\nvalue\r\n--b--", ErrWrongMultipart},
+		{"Huge part header", "--b\r\nx-big: " + strings.Repeat("a", 20000) + "\r\n\r\nvalue\r\n--b--", ErrPartHeadersTooLarge},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := NewMultipartReader(strings.NewReader(tc.body), "b")
+			var err error
+			for err == nil {
+				var part *Part
+				part, err = mr.NextPart()
+				if err == nil {
+					_, err = io.ReadAll(part)
+				}
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestMultipartReaderBoundaryPrefixInContent(t *testing.T) {
+	content := "a\r\n--boundaryX\r\nb\r\n--boundary-not\r\n--boundary \tx"
+	body := "--boundary\r\n" +
+		"content-disposition: form-data; name=\"a\"\r\n\r\n" +
+		content + "\r\n" +
+		"--boundary \t\r\n" +
+		"content-disposition: form-data; name=\"b\"\r\n\r\n" +
+		"second\r\n" +
+		"--boundary--\r\n"
+
+	readers := map[string]func(string) io.Reader{
+		"Whole":    func(s string) io.Reader { return strings.NewReader(s) },
+		"One byte": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+	}
+
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			mr := NewMultipartReader(newReader(body), "boundary")
+
+			part, err := mr.NextPart()
+			require.NoError(t, err)
+			data, err := io.ReadAll(part)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(data))
+
+			part, err = mr.NextPart()
+			require.NoError(t, err)
+			assert.Equal(t, "b", part.FormName())
+			data, err = io.ReadAll(part)
+			require.NoError(t, err)
+			assert.Equal(t, "second", string(data))
+
+			_, err = mr.NextPart()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
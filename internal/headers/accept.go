@@ -0,0 +1,239 @@
+package headers
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrNotAcceptable = errors.New("no acceptable representation")
+
+// AcceptItem is one element of an Accept-style list: a media range,
+// language range, charset or coding with its weight. Params holds the
+// parameters before q, names lower-cased and values unquoted.
+type AcceptItem struct {
+	Value  string
+	Q      float64
+	Params map[string]string
+}
+
+// ParseAccept parses an Accept, Accept-Language, Accept-Charset or
+// Accept-Encoding value, highest weight first; equal weights keep their
+// order. Elements with an invalid q are dropped.
+func ParseAccept(value string) []AcceptItem {
+	var items []AcceptItem
+
+	for _, element := range splitQuoted(value, ',') {
+		parts := splitQuoted(element, ';')
+		v := strings.TrimSpace(parts[0])
+		if v == "" {
+			continue
+		}
+
+		item := AcceptItem{Value: v, Q: 1}
+		valid := true
+		for _, param := range parts[1:] {
+			name, val, _ := strings.Cut(param, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			val = unquote(strings.TrimSpace(val))
+			if name == "" {
+				continue
+			}
+
+			if name == "q" {
+				q, ok := parseQ(val)
+				valid = ok
+				item.Q = q
+				// anything after q is an accept extension
+				break
+			}
+
+			if item.Params == nil {
+				item.Params = map[string]string{}
+			}
+			item.Params[name] = val
+		}
+
+		if valid {
+			items = append(items, item)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Q > items[j].Q
+	})
+	return items
+}
+
+// parseQ checks the qvalue grammar of RFC 9110 section 12.4.2:
+// "0" [ "." 0*3DIGIT ] or "1" [ "." 0*3"0" ]. ParseFloat alone would take
+// forms like NaN, 1e0 or 0x1p-1.
+func parseQ(s string) (float64, bool) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if (whole != "0" && whole != "1") || len(frac) > 3 {
+		return 0, false
+	}
+	for i := 0; i < len(frac); i++ {
+		if frac[i] < '0' || frac[i] > '9' || (whole == "1" && frac[i] != '0') {
+			return 0, false
+		}
+	}
+
+	q, err := strconv.ParseFloat(s, 64)
+	return q, err == nil
+}
+
+// Negotiate picks the offer the client prefers most according to the
+// Accept-style field key ("accept", "accept-language", "accept-charset" or
+// "accept-encoding"). The most specific range matching an offer decides its
+// weight; ties go to the earlier offer. A missing field accepts the first
+// offer, and ErrNotAcceptable means a 406 is due.
+func (h Headers) Negotiate(key string, offers ...string) (string, error) {
+	if len(offers) == 0 {
+		return "", ErrNotAcceptable
+	}
+
+	value, ok := h.Get(strings.ToLower(key))
+	if !ok || strings.TrimSpace(value) == "" {
+		return offers[0], nil
+	}
+
+	var match func(item AcceptItem, offer string) int
+	switch strings.ToLower(key) {
+	case "accept":
+		match = matchMediaRange
+	case "accept-language":
+		match = matchLanguageRange
+	default:
+		match = matchToken
+	}
+
+	return negotiate(ParseAccept(value), offers, match)
+}
+
+// match functions report how specific a matching range is, -1 meaning it
+// doesn't match at all.
+func negotiate(items []AcceptItem, offers []string, match func(AcceptItem, string) int) (string, error) {
+	best := ""
+	bestQ := 0.0
+
+	for _, offer := range offers {
+		q := 0.0
+		specificity := -1
+		for _, item := range items {
+			if s := match(item, offer); s > specificity {
+				specificity = s
+				q = item.Q
+			}
+		}
+
+		if q > bestQ {
+			best = offer
+			bestQ = q
+		}
+	}
+
+	if best == "" {
+		return "", ErrNotAcceptable
+	}
+	return best, nil
+}
+
+func matchMediaRange(item AcceptItem, offer string) int {
+	offerType, offerParams := parseOffer(offer)
+	typ, subtype, ok := strings.Cut(strings.ToLower(item.Value), "/")
+	if !ok {
+		return -1
+	}
+	offerMain, offerSub, _ := strings.Cut(offerType, "/")
+
+	specificity := 0
+	switch {
+	case typ == "*" && subtype == "*":
+	case typ == offerMain && subtype == "*":
+		specificity = 1
+	case typ == offerMain && subtype == offerSub:
+		specificity = 2
+	default:
+		return -1
+	}
+
+	for name, val := range item.Params {
+		if !strings.EqualFold(offerParams[name], val) {
+			return -1
+		}
+		specificity++
+	}
+	return specificity
+}
+
+func parseOffer(offer string) (string, map[string]string) {
+	parts := splitQuoted(offer, ';')
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		name, val, _ := strings.Cut(param, "=")
+		params[strings.ToLower(strings.TrimSpace(name))] = unquote(strings.TrimSpace(val))
+	}
+	return strings.ToLower(strings.TrimSpace(parts[0])), params
+}
+
+// matchLanguageRange does RFC 4647 basic filtering: "en" matches "en-GB".
+func matchLanguageRange(item AcceptItem, offer string) int {
+	if item.Value == "*" {
+		return 0
+	}
+
+	r := strings.ToLower(item.Value)
+	tag := strings.ToLower(offer)
+	if tag == r || strings.HasPrefix(tag, r+"-") {
+		return len(r)
+	}
+	return -1
+}
+
+func matchToken(item AcceptItem, offer string) int {
+	switch {
+	case item.Value == "*":
+		return 0
+	case strings.EqualFold(item.Value, offer):
+		return 1
+	}
+	return -1
+}
+
+// splitQuoted splits s at sep outside of quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
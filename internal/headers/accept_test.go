@@ -0,0 +1,98 @@
+package headers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccept(t *testing.T) {
+	items := ParseAccept(`text/html;level=1;q=0.5, application/json, text/*;q=abc, text/plain; format="a,b;c"; q=0.8; ext=1, , image/png;q=0`)
+	require.Len(t, items, 4)
+
+	assert.Equal(t, AcceptItem{Value: "application/json", Q: 1}, items[0])
+	assert.Equal(t, AcceptItem{Value: "text/plain", Q: 0.8, Params: map[string]string{"format": "a,b;c"}}, items[1])
+	assert.Equal(t, AcceptItem{Value: "text/html", Q: 0.5, Params: map[string]string{"level": "1"}}, items[2])
+	assert.Equal(t, AcceptItem{Value: "image/png", Q: 0}, items[3])
+}
+
+func TestParseAcceptQValue(t *testing.T) {
+	tests := []struct {
+		q     string
+		valid bool
+		want  float64
+	}{
+		{"1", true, 1},
+		{"1.", true, 1},
+		{"1.000", true, 1},
+		{"0", true, 0},
+		{"0.5", true, 0.5},
+		{"0.123", true, 0.123},
+		{"1.001", false, 0},
+		{"0.12345", false, 0},
+		{"NaN", false, 0},
+		{"Inf", false, 0},
+		{"+Inf", false, 0},
+		{"1e0", false, 0},
+		{"0x1p-1", false, 0},
+		{".5", false, 0},
+		{"00.5", false, 0},
+		{"-0", false, 0},
+		{"", false, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.q, func(t *testing.T) {
+			items := ParseAccept("text/html;q=" + tc.q)
+			if !tc.valid {
+				assert.Empty(t, items)
+				return
+			}
+			require.Len(t, items, 1)
+			assert.Equal(t, tc.want, items[0].Q)
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		value    string
+		offers   []string
+		expected string
+		err      error
+	}{
+		{"Missing field", "accept", "", []string{"text/html", "application/json"}, "text/html", nil},
+		{"Exact type", "accept", "application/json", []string{"text/html", "application/json"}, "application/json", nil},
+		{"Highest weight", "accept", "text/html;q=0.5, application/json;q=0.9", []string{"text/html", "application/json"}, "application/json", nil},
+		{"Server order on ties", "accept", "*/*", []string{"text/html", "application/json"}, "text/html", nil},
+		{"Subtype wildcard", "accept", "image/*", []string{"text/html", "image/webp"}, "image/webp", nil},
+		{"Specific range wins", "accept", "text/*;q=0.9, text/html;q=0.1", []string{"text/html", "text/plain"}, "text/plain", nil},
+		{"Excluded by q=0", "accept", "*/*, text/html;q=0", []string{"text/html", "text/plain"}, "text/plain", nil},
+		{"Parameters", "accept", "text/html;charset=utf-8", []string{"text/html; charset=latin1", "text/html; charset=UTF-8"}, "text/html; charset=UTF-8", nil},
+		{"Case insensitive", "Accept", "TEXT/HTML", []string{"text/html"}, "text/html", nil},
+		{"Not acceptable", "accept", "application/xml", []string{"text/html", "application/json"}, "", ErrNotAcceptable},
+		{"Language prefix", "accept-language", "en;q=0.8, fr", []string{"en-GB", "de"}, "en-GB", nil},
+		{"Language preference", "accept-language", "fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5", []string{"en-US", "fr-FR", "de"}, "fr-FR", nil},
+		{"Language wildcard", "accept-language", "*", []string{"de", "en"}, "de", nil},
+		{"Language no prefix match", "accept-language", "en-GB", []string{"en"}, "", ErrNotAcceptable},
+		{"Charset", "accept-charset", "iso-8859-1, utf-8;q=0.9", []string{"UTF-8", "ISO-8859-1"}, "ISO-8859-1", nil},
+		{"Charset wildcard", "accept-charset", "*;q=0.1, utf-8;q=0", []string{"utf-8", "utf-16"}, "utf-16", nil},
+		{"No offers", "accept", "*/*", nil, "", ErrNotAcceptable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHeaders()
+			if tc.value != "" {
+				h.Add(tc.key, tc.value)
+			}
+
+			offer, err := h.Negotiate(tc.key, tc.offers...)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.expected, offer)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package headers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func FuzzHeadersParse(f *testing.F) {
+	seeds := []string{
+		"Host: localhost:42069\r\n\r\n",
+		"User-Agent: Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0\r\n",
+		"Accept: text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8\r\n",
+		"Cookie: session=38afes7a8; theme=dark\r\n",
+		"\r\n",
+		"Transfer-Encoding : chunked\r\n",
+		"Transfer-Encoding:\tchunked\r\n",
+		"X-Foo: bar\nTransfer-Encoding: chunked\r\n",
+		" Content-Length: 5\r\n",
+		"Content-Length: 5\x00\r\n",
+		"Ho@st: localhost\r\n",
+		"no colon\r\n",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h := NewHeaders()
+		n, done, err := h.Parse(data)
+		if err != nil {
+			return
+		}
+
+		if n > len(data) {
+			t.Fatalf("consumed %d of %d bytes", n, len(data))
+		}
+
+		if n > 0 && !bytes.HasSuffix(data[:n], []byte(lineSeparator)) {
+			t.Fatalf("consumed %q which does not end a line", data[:n])
+		}
+
+		if n == 0 || done {
+			return
+		}
+
+		if len(h) != 1 {
+			t.Fatalf("one field line produced %d headers", len(h))
+		}
+
+		for key, value := range h {
+			if key != strings.ToLower(key) {
+				t.Fatalf("key %q is not lower-cased", key)
+			}
+
+			line := []byte(key + ": " + value + lineSeparator)
+			m, k, v, _, err := ParseField(line)
+			if err != nil {
+				t.Fatalf("re-parse of %q: %v", line, err)
+			}
+			if m != len(line) || k != key || v != value {
+				t.Fatalf("round trip of %q gave %q: %q", line, k, v)
+			}
+		}
+	})
+}
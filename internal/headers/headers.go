@@ -5,7 +5,6 @@ import (
 	"errors"
 	"maps"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -15,8 +14,9 @@ const valuesSeparator = ","
 var keyReg = regexp.MustCompile("^[a-zA-Z0-9!#$%&'*+-.^_`|]+$")
 
 var (
-	ErrWrongFormat    = errors.New("wrong format")
-	ErrWrongKeyFormat = errors.New("wrong key format")
+	ErrWrongFormat      = errors.New("wrong format")
+	ErrWrongKeyFormat   = errors.New("wrong key format")
+	ErrWrongValueFormat = errors.New("wrong value format")
 )
 
 type Headers map[string]string
@@ -30,52 +30,110 @@ func (h Headers) Get(key string) (string, bool) {
 	return val, ok
 }
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+// separators overrides valuesSeparator for fields that aren't comma
+// separated lists. Set-Cookie lines can't be combined at all and are kept
+// apart by a newline, which no field value can contain.
+var separators = map[string]string{
+	"cookie":     "; ",
+	"set-cookie": lineSeparator[1:],
+}
+
+func (h Headers) Add(key, value string) {
+	key = strings.ToLower(key)
+
+	oldVal, ok := h[key]
+	if !ok {
+		h[key] = value
+		return
+	}
+
+	sep, ok := separators[key]
+	if !ok {
+		sep = valuesSeparator
+	}
+	h[key] = oldVal + sep + value
+}
+
+// Lines returns the values of key as they go on the wire, one per field
+// line.
+func (h Headers) Lines(key string) []string {
+	value, ok := h[key]
+	if !ok {
+		return nil
+	}
+	return strings.Split(value, lineSeparator[1:])
+}
+
+// HasToken reports whether the comma separated list in key contains token,
+// compared case-insensitively.
+func (h Headers) HasToken(key, token string) bool {
+	value, ok := h.Get(key)
+	if !ok {
+		return false
+	}
+
+	for _, v := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseField parses a single field line from data. The returned key is
+// lower-cased; done is true when data starts with the empty line ending
+// the field section.
+func ParseField(data []byte) (n int, key string, value string, done bool, err error) {
 	nlIdx := bytes.Index(data, []byte(lineSeparator))
 
 	if nlIdx == -1 {
-		return 0, false, nil
+		return 0, "", "", false, nil
 	}
 
 	if nlIdx == 0 {
-		return 2, true, nil
+		return 2, "", "", true, nil
 	}
 
-	done = false
-
 	colonIdx := bytes.Index(data[:nlIdx], []byte(":"))
 	if colonIdx == -1 {
-		return 0, done, ErrWrongFormat
+		return 0, "", "", false, ErrWrongFormat
 	}
 
 	left := string(data[0:colonIdx])
 	right := string(data[colonIdx+1 : nlIdx])
 
 	if strings.HasSuffix(left, " ") {
-		return 0, done, ErrWrongFormat
+		return 0, "", "", false, ErrWrongFormat
 	}
 
 	left = strings.Trim(left, " ")
 	right = strings.Trim(right, " ")
 
 	if !keyReg.MatchString(left) {
-		return 0, done, ErrWrongKeyFormat
+		return 0, "", "", false, ErrWrongKeyFormat
 	}
 
-	key := strings.ToLower(left)
+	// a bare CR or LF inside a value is a classic request smuggling vector
+	if strings.ContainsAny(right, "\r\n\x00") {
+		return 0, "", "", false, ErrWrongValueFormat
+	}
 
-	oldVal, ok := h[key]
-	if ok {
-		h[key] = oldVal + valuesSeparator + right
-	} else {
-		h[key] = right
+	return nlIdx + 2, strings.ToLower(left), right, false, nil
+}
+
+func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+	n, key, value, done, err := ParseField(data)
+	if err != nil || done || n == 0 {
+		return n, done, err
 	}
-	return nlIdx + 2, done, nil
+
+	h.Add(key, value)
+	return n, false, nil
 }
 
 func (h Headers) SetDefault(contentLen int, customHeaders map[string]string) {
 
-	h["content-length"] = strconv.Itoa(contentLen)
+	h.SetContentLength(int64(contentLen))
 	h["connection"] = "close"
 	h["content-type"] = "text/plain"
 
@@ -0,0 +1,48 @@
+package headers
+
+import (
+	"testing"
+)
+
+func BenchmarkHeadersParse(b *testing.B) {
+	data := []byte("Host: localhost:42069\r\n" +
+		"User-Agent: curl/7.81.0\r\n" +
+		"Accept: */*\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: 1024\r\n" +
+		"\r\n")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		h := NewHeaders()
+		read := 0
+		for {
+			n, done, err := h.Parse(data[read:])
+			if err != nil {
+				b.Fatal(err)
+			}
+			read += n
+			if done {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkHeadersParseRepeatedKey(b *testing.B) {
+	data := []byte("Accept: text/html\r\n")
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		h := NewHeaders()
+		for j := 0; j < 8; j++ {
+			_, _, err := h.Parse(data)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
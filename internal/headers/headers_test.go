@@ -67,6 +67,14 @@ func TestHeadersParse(t *testing.T) {
 			checkKey: false,
 			notes:    "Invalid header format",
 		},
+		{
+			input:    "X-Foo: bar\nTransfer-Encoding: chunked\r\n\r\n",
+			n:        0,
+			done:     false,
+			err:      ErrWrongValueFormat,
+			checkKey: false,
+			notes:    "Bare line feed in value",
+		},
 	}
 
 	for _, tt := range tests {
@@ -101,3 +109,27 @@ func TestHeadersParseExistingKey(t *testing.T) {
 	assert.Equal(t, err, nil)
 	assert.Equal(t, headers["set-person"], "person1,person2")
 }
+
+func TestHeadersAddUncombinable(t *testing.T) {
+	h := NewHeaders()
+	h.Add("Set-Cookie", "a=1; Expires=Sun, 06 Nov 1994 08:49:37 GMT")
+	h.Add("set-cookie", "b=2")
+	h.Add("Cookie", "a=1")
+	h.Add("cookie", "b=2")
+
+	assert.Equal(t, []string{"a=1; Expires=Sun, 06 Nov 1994 08:49:37 GMT", "b=2"}, h.Lines("set-cookie"))
+	assert.Equal(t, "a=1; b=2", h["cookie"])
+	assert.Equal(t, []string{"a=1; b=2"}, h.Lines("cookie"))
+	assert.Nil(t, h.Lines("missing"))
+}
+
+func TestHeadersHasToken(t *testing.T) {
+	h := Headers{"connection": "keep-alive, Upgrade", "upgrade": "websocket"}
+
+	assert.True(t, h.HasToken("connection", "upgrade"))
+	assert.True(t, h.HasToken("connection", "keep-alive"))
+	assert.True(t, h.HasToken("upgrade", "WebSocket"))
+	assert.False(t, h.HasToken("connection", "close"))
+	assert.False(t, h.HasToken("connection", "keep"))
+	assert.False(t, h.HasToken("missing", "upgrade"))
+}
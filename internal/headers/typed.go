@@ -0,0 +1,193 @@
+package headers
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the IMF-fixdate form of an HTTP-date, the only one sent.
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// obsolete HTTP-date forms recipients still have to accept
+const (
+	rfc850Format  = "Monday, 02-Jan-06 15:04:05 GMT"
+	asctimeFormat = "Mon Jan _2 15:04:05 2006"
+)
+
+var (
+	ErrWrongContentLength = errors.New("wrong content length")
+	ErrWrongMediaType     = errors.New("wrong media type")
+	ErrWrongDate          = errors.New("wrong http date")
+)
+
+// ParseTime parses an HTTP-date in any of the three forms of RFC 9110
+// section 5.6.7.
+func ParseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{TimeFormat, rfc850Format, asctimeFormat} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrWrongDate
+}
+
+// FormatTime formats t as an IMF-fixdate.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// Time returns the date in field key; ok is false when it is missing or
+// malformed.
+func (h Headers) Time(key string) (time.Time, bool) {
+	value, ok := h.Get(key)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := ParseTime(value)
+	return t, err == nil
+}
+
+func (h Headers) SetTime(key string, t time.Time) {
+	h[strings.ToLower(key)] = FormatTime(t)
+}
+
+// ContentLength returns the validated Content-Length, or -1 when there is
+// none. A list of identical values, as left by repeated fields, is accepted
+// as RFC 9110 section 8.6 allows.
+func (h Headers) ContentLength() (int64, error) {
+	value, ok := h.Get("content-length")
+	if !ok {
+		return -1, nil
+	}
+
+	length := int64(-1)
+	for _, v := range strings.Split(value, valuesSeparator) {
+		n, err := parseLength(strings.TrimSpace(v))
+		if err != nil || (length >= 0 && n != length) {
+			return -1, ErrWrongContentLength
+		}
+		length = n
+	}
+	return length, nil
+}
+
+func (h Headers) SetContentLength(length int64) {
+	h["content-length"] = strconv.FormatInt(length, 10)
+}
+
+// parseLength accepts digits only; ParseInt would also take a sign.
+func parseLength(s string) (int64, error) {
+	if s == "" {
+		return 0, ErrWrongContentLength
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, ErrWrongContentLength
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, ErrWrongContentLength
+	}
+	return n, nil
+}
+
+// MediaType parses the Content-Type field; the type is empty when the field
+// is missing.
+func (h Headers) MediaType() (string, map[string]string, error) {
+	value, ok := h.Get("content-type")
+	if !ok {
+		return "", nil, nil
+	}
+	return ParseMediaType(value)
+}
+
+// SetMediaType sets Content-Type from a media type and its parameters.
+func (h Headers) SetMediaType(mediaType string, params map[string]string) {
+	h["content-type"] = FormatMediaType(mediaType, params)
+}
+
+// ParseMediaType splits a media type like `text/html; charset="utf-8"`
+// into the lower-cased type and its parameters, names lower-cased and
+// values unquoted.
+func ParseMediaType(value string) (string, map[string]string, error) {
+	mediaType, params, err := ParseParams(value)
+	if err != nil {
+		return "", nil, ErrWrongMediaType
+	}
+
+	typ, subtype, found := strings.Cut(mediaType, "/")
+	if !found || !IsToken(typ) || !IsToken(subtype) {
+		return "", nil, ErrWrongMediaType
+	}
+	return mediaType, params, nil
+}
+
+// ParseParams splits a field value followed by parameters, like the
+// `form-data; name="file"` of Content-Disposition, the same way.
+func ParseParams(value string) (string, map[string]string, error) {
+	parts := splitQuoted(value, ';')
+
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		if strings.TrimSpace(param) == "" {
+			continue
+		}
+
+		name, val, found := strings.Cut(param, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !found || !IsToken(name) {
+			return "", nil, ErrWrongFormat
+		}
+		params[name] = unquote(strings.TrimSpace(val))
+	}
+
+	return strings.ToLower(strings.TrimSpace(parts[0])), params, nil
+}
+
+// FormatMediaType is the inverse of ParseMediaType, quoting values where
+// needed. Parameters are sorted for a stable result.
+func FormatMediaType(mediaType string, params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(strings.ToLower(mediaType))
+	for _, name := range names {
+		b.WriteString("; ")
+		b.WriteString(strings.ToLower(name))
+		b.WriteByte('=')
+		b.WriteString(quote(params[name]))
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	if IsToken(s) {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// IsToken checks for the token grammar of RFC 9110 section 5.6.2.
+func IsToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package headers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	expected := time.Date(1994, 11, 6, 8, 49, 37, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		err   error
+	}{
+		{"Sun, 06 Nov 1994 08:49:37 GMT", nil},
+		{"Sunday, 06-Nov-94 08:49:37 GMT", nil},
+		{"Sun Nov  6 08:49:37 1994", nil},
+		{" Sun, 06 Nov 1994 08:49:37 GMT ", nil},
+		{"Sun, 06 Nov 1994 08:49:37 PST", ErrWrongDate},
+		{"1994-11-06T08:49:37Z", ErrWrongDate},
+		{"", ErrWrongDate},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			date, err := ParseTime(tc.value)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, expected.Equal(date))
+		})
+	}
+
+	h := NewHeaders()
+	h.SetTime("Last-Modified", expected.In(time.FixedZone("CET", 3600)))
+	assert.Equal(t, "Sun, 06 Nov 1994 08:49:37 GMT", h["last-modified"])
+
+	date, ok := h.Time("last-modified")
+	assert.True(t, ok)
+	assert.True(t, expected.Equal(date))
+
+	_, ok = h.Time("date")
+	assert.False(t, ok)
+}
+
+func TestContentLength(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		err      error
+	}{
+		{"0", 0, nil},
+		{"1024", 1024, nil},
+		{" 42 ", 42, nil},
+		{"42,42", 42, nil},
+		{"42, 43", -1, ErrWrongContentLength},
+		{"+42", -1, ErrWrongContentLength},
+		{"-1", -1, ErrWrongContentLength},
+		{"0x10", -1, ErrWrongContentLength},
+		{"", -1, ErrWrongContentLength},
+		{"99999999999999999999", -1, ErrWrongContentLength},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			h := Headers{"content-length": tc.value}
+			length, err := h.ContentLength()
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.expected, length)
+		})
+	}
+
+	length, err := NewHeaders().ContentLength()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), length)
+}
+
+func TestParseMediaType(t *testing.T) {
+	tests := []struct {
+		value     string
+		mediaType string
+		params    map[string]string
+		err       error
+	}{
+		{"text/plain", "text/plain", map[string]string{}, nil},
+		{"Text/HTML; Charset=UTF-8", "text/html", map[string]string{"charset": "UTF-8"}, nil},
+		{`multipart/form-data; boundary="a;b \"c\""`, "multipart/form-data", map[string]string{"boundary": `a;b "c"`}, nil},
+		{"application/json;", "application/json", map[string]string{}, nil},
+		{"text", "", nil, ErrWrongMediaType},
+		{"text/", "", nil, ErrWrongMediaType},
+		{"text/plain; charset", "", nil, ErrWrongMediaType},
+		{"text/plain; a b=c", "", nil, ErrWrongMediaType},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			mediaType, params, err := ParseMediaType(tc.value)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.mediaType, mediaType)
+			assert.Equal(t, tc.params, params)
+		})
+	}
+}
+
+func TestSetMediaType(t *testing.T) {
+	h := NewHeaders()
+	h.SetMediaType("Multipart/Form-Data", map[string]string{"boundary": "a b", "charset": "utf-8"})
+	assert.Equal(t, `multipart/form-data; boundary="a b"; charset=utf-8`, h["content-type"])
+
+	mediaType, params, err := h.MediaType()
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+	assert.Equal(t, map[string]string{"boundary": "a b", "charset": "utf-8"}, params)
+
+	mediaType, _, err = NewHeaders().MediaType()
+	assert.NoError(t, err)
+	assert.Empty(t, mediaType)
+}
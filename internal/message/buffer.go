@@ -0,0 +1,82 @@
+package message
+
+import (
+	"errors"
+	"io"
+)
+
+// Buffer holds what was read from a connection but not parsed yet, so bytes
+// that arrive after one message are kept for the next. The zero value is
+// ready once Reset gave it a reader and a buffer.
+type Buffer struct {
+	r     io.Reader
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+func (b *Buffer) Reset(r io.Reader, buf []byte) {
+	b.r = r
+	b.buf = buf
+	b.start = 0
+	b.end = 0
+	b.eof = false
+}
+
+// Bytes returns the unparsed bytes. The slice is only valid until the next
+// call to Fill.
+func (b *Buffer) Bytes() []byte {
+	return b.buf[b.start:b.end]
+}
+
+// Buf returns the whole underlying buffer, which may have grown.
+func (b *Buffer) Buf() []byte {
+	return b.buf
+}
+
+// Consume drops the first n unparsed bytes.
+func (b *Buffer) Consume(n int) {
+	b.start += n
+	if b.start == b.end {
+		b.start = 0
+		b.end = 0
+	}
+}
+
+// EOF reports whether the reader ended, so no more bytes will arrive.
+func (b *Buffer) EOF() bool {
+	return b.eof
+}
+
+// SetEOF treats the reader as ended, as a server does once a read deadline
+// passed.
+func (b *Buffer) SetEOF() {
+	b.eof = true
+}
+
+// Fill reads more input after making room for it: unparsed bytes are moved
+// to the front, or the buffer grows when they fill it. io.EOF is recorded
+// for EOF rather than returned.
+func (b *Buffer) Fill() error {
+	if b.end == len(b.buf) {
+		if b.start > 0 {
+			b.end = copy(b.buf, b.buf[b.start:b.end])
+			b.start = 0
+		} else {
+			tmpBuff := make([]byte, len(b.buf)*2)
+			copy(tmpBuff, b.buf)
+			b.buf = tmpBuff
+		}
+	}
+
+	n, err := b.r.Read(b.buf[b.end:])
+	b.end += n
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		b.eof = true
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package message
+
+import (
+	"errors"
+	"httpfromtcp/internal/chunked"
+	"httpfromtcp/internal/headers"
+)
+
+// MaxFieldsBytes limits a header or trailer section.
+const MaxFieldsBytes = 64 * 1024
+
+type State int
+
+var (
+	StateStartLine State = 0
+	StateHeaders   State = 1
+	StateBody      State = 2
+	StateTrailers  State = 3
+	StateDone      State = 4
+)
+
+type EventType int
+
+var (
+	EventStartLine   EventType = 0
+	EventHeader      EventType = 1
+	EventHeadersDone EventType = 2
+	EventBodyChunk   EventType = 3
+	EventTrailer     EventType = 4
+	EventComplete    EventType = 5
+)
+
+var (
+	ErrWrongBodyLength = errors.New("wrong body length")
+	ErrFieldsTooLarge  = errors.New("header or trailer section too large")
+	ErrBodyTooLarge    = errors.New("body too large")
+)
+
+// Event is a single piece of a parsed message. The start line itself is
+// kept by the caller's ParseStartLine; Key and Value are set for
+// EventHeader and EventTrailer, Data for EventBodyChunk. Data aliases the
+// slice passed to Feed and is only valid until the next call to Feed.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value string
+	Data  []byte
+}
+
+// Framing is how the body after the header section is delimited. With
+// neither Chunked nor UntilEOF set the body is Length bytes long.
+type Framing struct {
+	Length   int64
+	Chunked  bool
+	UntilEOF bool
+}
+
+// Kind holds what differs between requests and responses.
+type Kind struct {
+	// ParseStartLine consumes the request or status line; 0 without an
+	// error means more input is needed.
+	ParseStartLine func(data []byte) (int, error)
+	// Framing is called once all headers are known.
+	Framing func(h headers.Headers) (Framing, error)
+	// ErrIncomplete is returned by Finish for a message cut short.
+	ErrIncomplete error
+}
+
+// Parser is a push-based HTTP/1.1 message parser: the caller owns the
+// reading and feeds the parser whatever bytes it has, then drains events
+// with Next. Everything after the start line is parsed here.
+type Parser struct {
+	// MaxBodySize limits the body without its chunked framing; zero means
+	// no limit.
+	MaxBodySize int64
+
+	kind     Kind
+	state    State
+	headers  headers.Headers
+	framing  Framing
+	decoder  *chunked.Decoder
+	bodyLeft int64
+	bodyLen  int64
+	// bytes of the current header or trailer section seen so far
+	fieldsLen int
+	events    []Event
+	head      int
+	err       error
+}
+
+func NewParser(kind Kind) *Parser {
+	return &Parser{
+		kind:    kind,
+		state:   StateStartLine,
+		headers: headers.NewHeaders(),
+		decoder: chunked.NewDecoder(),
+	}
+}
+
+func (p *Parser) Reset() {
+	p.state = StateStartLine
+	clear(p.headers)
+	p.framing = Framing{}
+	p.decoder.Reset()
+	p.bodyLeft = 0
+	p.bodyLen = 0
+	p.fieldsLen = 0
+	p.events = p.events[:0]
+	p.head = 0
+	p.err = nil
+}
+
+func (p *Parser) State() State {
+	return p.state
+}
+
+func (p *Parser) Done() bool {
+	return p.state == StateDone
+}
+
+// CloseDelimited reports whether the body ends only when the connection is
+// closed.
+func (p *Parser) CloseDelimited() bool {
+	return p.framing.UntilEOF
+}
+
+// Feed parses as much of data as possible and returns the number of bytes
+// consumed. Unconsumed bytes must be fed again, followed by more input.
+func (p *Parser) Feed(data []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+
+	read := 0
+	for p.state != StateDone {
+		n, err := p.parse(data[read:])
+		if err != nil {
+			p.err = err
+			return read, err
+		}
+
+		if n == 0 {
+			break
+		}
+
+		read += n
+	}
+
+	return read, nil
+}
+
+// Finish tells the parser no more input will follow, which ends a
+// close-delimited body.
+func (p *Parser) Finish() error {
+	if p.err != nil {
+		return p.err
+	}
+
+	if p.state == StateDone {
+		return nil
+	}
+
+	if p.state == StateBody && p.framing.UntilEOF {
+		p.complete()
+		return nil
+	}
+
+	if p.state == StateBody && !p.framing.Chunked {
+		p.err = ErrWrongBodyLength
+	} else {
+		p.err = p.kind.ErrIncomplete
+	}
+
+	return p.err
+}
+
+// Next returns the oldest event not yet returned.
+func (p *Parser) Next() (Event, bool) {
+	if p.head >= len(p.events) {
+		p.events = p.events[:0]
+		p.head = 0
+		return Event{}, false
+	}
+
+	ev := p.events[p.head]
+	p.events[p.head] = Event{}
+	p.head++
+	return ev, true
+}
+
+func (p *Parser) emit(ev Event) {
+	p.events = append(p.events, ev)
+}
+
+func (p *Parser) complete() {
+	p.state = StateDone
+	p.emit(Event{Type: EventComplete})
+}
+
+func (p *Parser) parse(data []byte) (int, error) {
+	switch p.state {
+	// parse start line
+	case StateStartLine:
+		n, err := p.kind.ParseStartLine(data)
+		if err != nil || n == 0 {
+			return 0, err
+		}
+
+		p.state = StateHeaders
+		p.emit(Event{Type: EventStartLine})
+		return n, nil
+
+	// parse headers
+	case StateHeaders:
+		n, key, value, done, err := headers.ParseField(data)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := p.countFields(n, len(data)); err != nil {
+			return 0, err
+		}
+
+		if done {
+			p.state = StateBody
+			p.fieldsLen = 0
+			p.emit(Event{Type: EventHeadersDone})
+			return n, p.startBody()
+		}
+
+		if n == 0 {
+			return 0, nil
+		}
+
+		p.headers.Add(key, value)
+		p.emit(Event{Type: EventHeader, Key: key, Value: value})
+		return n, nil
+
+	// parse body
+	case StateBody:
+		if p.framing.Chunked {
+			n, chunk, done, err := p.decoder.Parse(data)
+			if err != nil {
+				return 0, err
+			}
+
+			if len(chunk) > 0 {
+				if err := p.countBody(len(chunk)); err != nil {
+					return 0, err
+				}
+				p.emit(Event{Type: EventBodyChunk, Data: chunk})
+			}
+
+			if done {
+				p.state = StateTrailers
+			}
+
+			return n, nil
+		}
+
+		if len(data) == 0 {
+			return 0, nil
+		}
+
+		if p.framing.UntilEOF {
+			if err := p.countBody(len(data)); err != nil {
+				return 0, err
+			}
+			p.emit(Event{Type: EventBodyChunk, Data: data})
+			return len(data), nil
+		}
+
+		l := int(min(int64(len(data)), p.bodyLeft))
+		p.bodyLeft -= int64(l)
+		p.emit(Event{Type: EventBodyChunk, Data: data[:l]})
+
+		if p.bodyLeft == 0 {
+			p.complete()
+		}
+
+		return l, nil
+
+	// parse trailers
+	case StateTrailers:
+		n, key, value, done, err := headers.ParseField(data)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := p.countFields(n, len(data)); err != nil {
+			return 0, err
+		}
+
+		if done {
+			p.complete()
+			return n, nil
+		}
+
+		if n == 0 {
+			return 0, nil
+		}
+
+		p.emit(Event{Type: EventTrailer, Key: key, Value: value})
+		return n, nil
+	}
+
+	return 0, errors.New("uknown parse error")
+}
+
+// countFields keeps a header or trailer section within MaxFieldsBytes, both
+// for consumed lines and for a line still waiting for its end.
+func (p *Parser) countFields(n int, available int) error {
+	if n == 0 {
+		if p.fieldsLen+available > MaxFieldsBytes {
+			return ErrFieldsTooLarge
+		}
+		return nil
+	}
+
+	p.fieldsLen += n
+	if p.fieldsLen > MaxFieldsBytes {
+		return ErrFieldsTooLarge
+	}
+	return nil
+}
+
+// countBody keeps bodies without a known length within MaxBodySize.
+func (p *Parser) countBody(n int) error {
+	p.bodyLen += int64(n)
+	if p.MaxBodySize > 0 && p.bodyLen > p.MaxBodySize {
+		return ErrBodyTooLarge
+	}
+	return nil
+}
+
+// startBody asks the caller for the body framing once all headers are
+// known.
+func (p *Parser) startBody() error {
+	framing, err := p.kind.Framing(p.headers)
+	if err != nil {
+		return err
+	}
+	p.framing = framing
+
+	if !framing.Chunked && !framing.UntilEOF {
+		if p.MaxBodySize > 0 && framing.Length > p.MaxBodySize {
+			return ErrBodyTooLarge
+		}
+		if framing.Length == 0 {
+			p.complete()
+			return nil
+		}
+		p.bodyLeft = framing.Length
+	}
+	return nil
+}
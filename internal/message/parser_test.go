@@ -0,0 +1,111 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errIncomplete = errors.New("incomplete")
+
+// lineParser is a Kind whose start line is any line and whose framing is
+// fixed by the test.
+func lineParser(framing Framing) *Parser {
+	return NewParser(Kind{
+		ParseStartLine: func(data []byte) (int, error) {
+			idx := bytes.Index(data, []byte("\r\n"))
+			if idx < 0 {
+				return 0, nil
+			}
+			return idx + 2, nil
+		},
+		Framing: func(h headers.Headers) (Framing, error) {
+			return framing, nil
+		},
+		ErrIncomplete: errIncomplete,
+	})
+}
+
+func parseAll(t *testing.T, p *Parser, data string, finish bool) (string, []EventType, error) {
+	t.Helper()
+
+	body := []byte{}
+	types := []EventType{}
+	n, err := p.Feed([]byte(data))
+	if err == nil && finish {
+		err = p.Finish()
+	}
+	for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+		types = append(types, ev.Type)
+		body = append(body, ev.Data...)
+	}
+	if err == nil && !finish {
+		assert.Equal(t, len(data), n)
+	}
+	return string(body), types, err
+}
+
+func TestParserFraming(t *testing.T) {
+	tests := []struct {
+		name    string
+		framing Framing
+		input   string
+		finish  bool
+		body    string
+		err     error
+	}{
+		{"No body", Framing{}, "START\r\nA: 1\r\n\r\n", false, "", nil},
+		{"Length", Framing{Length: 3}, "START\r\n\r\nabc", false, "abc", nil},
+		{"Chunked", Framing{Chunked: true}, "START\r\n\r\n3\r\nabc\r\n0\r\nT: 1\r\n\r\n", false, "abc", nil},
+		{"Until EOF", Framing{UntilEOF: true}, "START\r\n\r\nabc", true, "abc", nil},
+		{"Short length", Framing{Length: 4}, "START\r\n\r\nabc", true, "abc", ErrWrongBodyLength},
+		{"Cut in headers", Framing{}, "START\r\nA: 1\r\n", true, "", errIncomplete},
+		{"Cut in chunks", Framing{Chunked: true}, "START\r\n\r\n3\r\nabc\r\n", true, "abc", errIncomplete},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, types, err := parseAll(t, lineParser(tc.framing), tc.input, tc.finish)
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.body, body)
+			if tc.err == nil {
+				assert.Equal(t, EventStartLine, types[0])
+				assert.Equal(t, EventComplete, types[len(types)-1])
+			}
+		})
+	}
+}
+
+func TestParserFieldsTooLarge(t *testing.T) {
+	p := lineParser(Framing{})
+	_, err := p.Feed([]byte("START\r\n" + strings.Repeat("X-Large: value\r\n", MaxFieldsBytes/16+1)))
+	assert.Equal(t, ErrFieldsTooLarge, err)
+
+	_, err = p.Feed([]byte("\r\n"))
+	assert.Equal(t, ErrFieldsTooLarge, err, "errors stick")
+}
+
+func TestBufferFill(t *testing.T) {
+	data := "START\r\nA: 1\r\n\r\nabcdefghSTART"
+	var b Buffer
+	b.Reset(iotest.OneByteReader(strings.NewReader(data)), make([]byte, 4))
+
+	p := lineParser(Framing{Length: 8})
+	for !p.Done() {
+		require.NoError(t, b.Fill())
+		n, err := p.Feed(b.Bytes())
+		require.NoError(t, err)
+		b.Consume(n)
+	}
+
+	for !b.EOF() {
+		require.NoError(t, b.Fill())
+	}
+	assert.Equal(t, "START", string(b.Bytes()))
+}
@@ -0,0 +1,327 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"httpfromtcp/internal/client"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultMaxFails = 3
+const defaultEjectDuration = 10 * time.Second
+const defaultHealthCheckInterval = 5 * time.Second
+const defaultRetries = 1
+const virtualNodes = 100
+
+type Strategy int
+
+var (
+	RoundRobin       Strategy = 0
+	LeastConnections Strategy = 1
+	ConsistentHash   Strategy = 2
+)
+
+var (
+	ErrNoUpstreams       = errors.New("no upstreams")
+	ErrNoHealthyUpstream = errors.New("no healthy upstream")
+	ErrUnknownStrategy   = errors.New("unknown strategy")
+)
+
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "round-robin":
+		return RoundRobin, nil
+	case "least-connections":
+		return LeastConnections, nil
+	case "consistent-hash":
+		return ConsistentHash, nil
+	}
+	return 0, ErrUnknownStrategy
+}
+
+type upstream struct {
+	url    *url.URL
+	active atomic.Int64
+
+	mu           sync.Mutex
+	fails        int
+	ejectedUntil time.Time
+	// unhealthy is set by active health checks
+	unhealthy bool
+}
+
+func (u *upstream) available(now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !u.unhealthy && !now.Before(u.ejectedUntil)
+}
+
+func (u *upstream) succeeded() {
+	u.mu.Lock()
+	u.fails = 0
+	u.mu.Unlock()
+}
+
+// failed counts a failure and ejects the upstream after maxFails
+// consecutive ones.
+func (u *upstream) failed(maxFails int, ejectFor time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.fails++
+	if maxFails > 0 && u.fails >= maxFails {
+		u.fails = 0
+		u.ejectedUntil = time.Now().Add(ejectFor)
+	}
+}
+
+func (u *upstream) setHealthy(healthy bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.unhealthy = !healthy
+	if healthy {
+		u.fails = 0
+		u.ejectedUntil = time.Time{}
+	}
+}
+
+type ringPoint struct {
+	hash     uint32
+	upstream *upstream
+}
+
+// LoadBalancer is a reverse proxy spreading requests over several upstreams.
+// Failing upstreams are ejected for a while and idempotent requests are
+// retried on another one.
+type LoadBalancer struct {
+	upstreams []*upstream
+	ring      []ringPoint
+	next      atomic.Uint64
+
+	Strategy    Strategy
+	StripPrefix string
+	Client      *client.Client
+	// HashKey picks what consistent hashing is keyed by; the client IP by
+	// default.
+	HashKey func(r *request.Request) string
+	// MaxFails consecutive failures eject an upstream for EjectDuration.
+	MaxFails      int
+	EjectDuration time.Duration
+	// Retries is how many other upstreams an idempotent request may try.
+	Retries int
+
+	HealthCheckPath     string
+	HealthCheckInterval time.Duration
+	healthClient        *client.Client
+	stop                chan struct{}
+	wg                  sync.WaitGroup
+}
+
+func NewLoadBalancer(upstreams []string) (*LoadBalancer, error) {
+	if len(upstreams) == 0 {
+		return nil, ErrNoUpstreams
+	}
+
+	lb := &LoadBalancer{
+		Strategy:            RoundRobin,
+		Client:              newUpstreamClient(),
+		HashKey:             clientIP,
+		MaxFails:            defaultMaxFails,
+		EjectDuration:       defaultEjectDuration,
+		Retries:             defaultRetries,
+		HealthCheckInterval: defaultHealthCheckInterval,
+		healthClient:        client.NewClient(),
+	}
+	lb.healthClient.Timeout = time.Second
+
+	for _, raw := range upstreams {
+		u, err := parseUpstream(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		up := &upstream{url: u}
+		lb.upstreams = append(lb.upstreams, up)
+		for i := 0; i < virtualNodes; i++ {
+			lb.ring = append(lb.ring, ringPoint{
+				hash:     crc32.ChecksumIEEE([]byte(u.String() + "#" + strconv.Itoa(i))),
+				upstream: up,
+			})
+		}
+	}
+
+	slices.SortFunc(lb.ring, func(a, b ringPoint) int {
+		return int(int64(a.hash) - int64(b.hash))
+	})
+
+	return lb, nil
+}
+
+// Handle is a server.Handler.
+func (lb *LoadBalancer) Handle(w io.Writer, r *request.Request) {
+	attempts := 1
+	if request.IsIdempotent(r.RequestLine.Method) {
+		attempts += lb.Retries
+	}
+
+	tried := map[*upstream]bool{}
+	err := ErrNoHealthyUpstream
+
+	for i := 0; i < attempts; i++ {
+		u := lb.pick(r, tried)
+		if u == nil {
+			break
+		}
+		tried[u] = true
+
+		s := newResponseStreamer(w, r.RequestLine.Method)
+		u.active.Add(1)
+		err = lb.Client.Stream(outgoing(r, u.url, lb.StripPrefix), s.handle)
+		u.active.Add(-1)
+
+		if err == nil {
+			u.succeeded()
+			return
+		}
+
+		// the client went away; neither the upstream's fault nor retryable
+		if s.writeErr != nil {
+			return
+		}
+
+		fmt.Printf("Upstream %s error: %v\n", u.url, err)
+		u.failed(lb.MaxFails, lb.EjectDuration)
+
+		// the response is already on its way, it can't be retried
+		if s.started {
+			return
+		}
+	}
+
+	if errors.Is(err, ErrNoHealthyUpstream) {
+		response.WriteError(w, response.SERVICE_UNAVAILABLE, err)
+		return
+	}
+	response.WriteError(w, upstreamStatus(err), err)
+}
+
+// pick chooses an available upstream that was not tried yet, or nil.
+func (lb *LoadBalancer) pick(r *request.Request, tried map[*upstream]bool) *upstream {
+	now := time.Now()
+	usable := func(u *upstream) bool {
+		return !tried[u] && u.available(now)
+	}
+
+	switch lb.Strategy {
+	case LeastConnections:
+		var best *upstream
+		for _, u := range lb.upstreams {
+			if usable(u) && (best == nil || u.active.Load() < best.active.Load()) {
+				best = u
+			}
+		}
+		return best
+
+	case ConsistentHash:
+		hash := crc32.ChecksumIEEE([]byte(lb.HashKey(r)))
+		start, _ := slices.BinarySearchFunc(lb.ring, hash, func(p ringPoint, h uint32) int {
+			return int(int64(p.hash) - int64(h))
+		})
+		for i := range lb.ring {
+			u := lb.ring[(start+i)%len(lb.ring)].upstream
+			if usable(u) {
+				return u
+			}
+		}
+		return nil
+	}
+
+	start := lb.next.Add(1) - 1
+	for i := range lb.upstreams {
+		u := lb.upstreams[(start+uint64(i))%uint64(len(lb.upstreams))]
+		if usable(u) {
+			return u
+		}
+	}
+	return nil
+}
+
+// CheckHealth requests HealthCheckPath from every upstream once; a 2xx or
+// 3xx answer marks it healthy.
+func (lb *LoadBalancer) CheckHealth() {
+	var wg sync.WaitGroup
+	for _, u := range lb.upstreams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// the check path is relative to the upstream's base path
+			target := u.url.JoinPath(lb.HealthCheckPath)
+			target.RawQuery = ""
+			req, err := client.NewRequest("GET", target.String(), nil)
+			if err == nil {
+				var res *response.Response
+				res, err = lb.healthClient.Do(req)
+				if err == nil && (res.StatusLine.StatusCode < 200 || res.StatusLine.StatusCode >= 400) {
+					err = fmt.Errorf("status %d", res.StatusLine.StatusCode)
+				}
+			}
+
+			if err != nil {
+				fmt.Printf("Health check of %s failed: %v\n", u.url, err)
+			}
+			u.setHealthy(err == nil)
+		}()
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks runs CheckHealth every HealthCheckInterval until
+// StopHealthChecks is called.
+func (lb *LoadBalancer) StartHealthChecks() {
+	lb.stop = make(chan struct{})
+	lb.wg.Add(1)
+
+	go func() {
+		defer lb.wg.Done()
+
+		ticker := time.NewTicker(lb.HealthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			lb.CheckHealth()
+			select {
+			case <-lb.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (lb *LoadBalancer) StopHealthChecks() {
+	if lb.stop == nil {
+		return
+	}
+	close(lb.stop)
+	lb.wg.Wait()
+	lb.stop = nil
+}
+
+func clientIP(r *request.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveNamed answers every request with name as the body; the health path
+// answers with healthStatus.
+func serveNamed(t *testing.T, name string, healthStatus *atomic.Int64) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				for {
+					r, err := request.RequestFromReader(conn)
+					if err != nil {
+						return
+					}
+
+					status := int64(200)
+					if r.RequestLine.RequestTarget == "/health" && healthStatus != nil {
+						status = healthStatus.Load()
+					}
+					fmt.Fprintf(conn, "HTTP/1.1 %d \r\nContent-Length: %d\r\n\r\n%s", status, len(name), name)
+				}
+			}()
+		}
+	}()
+
+	return "http://" + listener.Addr().String()
+}
+
+func closedUpstream(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr
+}
+
+func balance(t *testing.T, lb *LoadBalancer, method string, remoteAddr string) *response.Response {
+	t.Helper()
+
+	r := parseRequest(t, method+" / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	r.RemoteAddr = remoteAddr
+
+	var out bytes.Buffer
+	lb.Handle(&out, r)
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	return res
+}
+
+func TestLoadBalancerRoundRobin(t *testing.T) {
+	lb, err := NewLoadBalancer([]string{serveNamed(t, "a", nil), serveNamed(t, "b", nil)})
+	require.NoError(t, err)
+
+	bodies := []string{}
+	for i := 0; i < 4; i++ {
+		bodies = append(bodies, string(balance(t, lb, "GET", "10.0.0.1:1000").Body))
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, bodies)
+}
+
+func TestLoadBalancerRetriesAndEjects(t *testing.T) {
+	lb, err := NewLoadBalancer([]string{closedUpstream(t), serveNamed(t, "b", nil)})
+	require.NoError(t, err)
+	lb.MaxFails = 1
+
+	res := balance(t, lb, "GET", "10.0.0.1:1000")
+	assert.EqualValues(t, 200, res.StatusLine.StatusCode)
+	assert.Equal(t, "b", string(res.Body))
+	assert.False(t, lb.upstreams[0].available(time.Now()))
+
+	// the dead upstream is ejected, so every request lands on b
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "b", string(balance(t, lb, "POST", "10.0.0.1:1000").Body))
+	}
+
+	lb.upstreams[0].setHealthy(true)
+	lb.next.Store(0)
+	res = balance(t, lb, "POST", "10.0.0.1:1000")
+	assert.Equal(t, response.BAD_GATEWAY, res.StatusLine.StatusCode)
+}
+
+func TestLoadBalancerLeastConnections(t *testing.T) {
+	lb, err := NewLoadBalancer([]string{serveNamed(t, "a", nil), serveNamed(t, "b", nil)})
+	require.NoError(t, err)
+	lb.Strategy = LeastConnections
+
+	lb.upstreams[0].active.Store(3)
+	lb.upstreams[1].active.Store(1)
+	assert.Equal(t, "b", string(balance(t, lb, "GET", "10.0.0.1:1000").Body))
+
+	lb.upstreams[1].active.Store(5)
+	assert.Equal(t, "a", string(balance(t, lb, "GET", "10.0.0.1:1000").Body))
+}
+
+func TestLoadBalancerConsistentHash(t *testing.T) {
+	lb, err := NewLoadBalancer([]string{
+		serveNamed(t, "a", nil),
+		serveNamed(t, "b", nil),
+		serveNamed(t, "c", nil),
+	})
+	require.NoError(t, err)
+	lb.Strategy = ConsistentHash
+
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		remoteAddr := fmt.Sprintf("10.0.0.%d:1000", i)
+		first := string(balance(t, lb, "GET", remoteAddr).Body)
+		second := string(balance(t, lb, "GET", remoteAddr).Body)
+		assert.Equal(t, first, second, remoteAddr)
+		seen[first] = true
+	}
+	assert.Len(t, seen, 3)
+}
+
+func TestLoadBalancerHealthChecks(t *testing.T) {
+	healthA := &atomic.Int64{}
+	healthA.Store(500)
+	healthB := &atomic.Int64{}
+	healthB.Store(200)
+
+	lb, err := NewLoadBalancer([]string{serveNamed(t, "a", healthA), serveNamed(t, "b", healthB)})
+	require.NoError(t, err)
+	lb.HealthCheckPath = "/health"
+
+	lb.CheckHealth()
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "b", string(balance(t, lb, "GET", "10.0.0.1:1000").Body))
+	}
+
+	healthB.Store(503)
+	lb.CheckHealth()
+	res := balance(t, lb, "GET", "10.0.0.1:1000")
+	assert.Equal(t, response.SERVICE_UNAVAILABLE, res.StatusLine.StatusCode)
+
+	healthA.Store(204)
+	lb.HealthCheckInterval = 10 * time.Millisecond
+	lb.StartHealthChecks()
+	time.Sleep(50 * time.Millisecond)
+	lb.StopHealthChecks()
+	assert.Equal(t, "a", string(balance(t, lb, "GET", "10.0.0.1:1000").Body))
+}
+
+func TestLoadBalancerHealthCheckPath(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	targets := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r, err := request.RequestFromReader(conn)
+		if err != nil {
+			return
+		}
+		targets <- r.RequestLine.RequestTarget
+		fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+	}()
+
+	lb, err := NewLoadBalancer([]string{"http://" + listener.Addr().String() + "/api/?key=1"})
+	require.NoError(t, err)
+	lb.HealthCheckPath = "/health"
+
+	lb.CheckHealth()
+	assert.Equal(t, "/api/health", <-targets)
+	assert.True(t, lb.upstreams[0].available(time.Now()))
+}
+
+// failingWriter stands for a client that went away.
+type failingWriter struct{}
+
+func (failingWriter) Write(b []byte) (int, error) {
+	return 0, net.ErrClosed
+}
+
+func TestLoadBalancerDownstreamErrors(t *testing.T) {
+	lb, err := NewLoadBalancer([]string{serveNamed(t, "a", nil)})
+	require.NoError(t, err)
+	lb.MaxFails = 1
+
+	for i := 0; i < 3; i++ {
+		r := parseRequest(t, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		lb.Handle(failingWriter{}, r)
+	}
+
+	assert.True(t, lb.upstreams[0].available(time.Now()))
+	assert.Equal(t, "a", string(balance(t, lb, "GET", "10.0.0.1:1000").Body))
+}
+
+func TestParseStrategy(t *testing.T) {
+	s, err := ParseStrategy("least-connections")
+	require.NoError(t, err)
+	assert.Equal(t, LeastConnections, s)
+
+	_, err = ParseStrategy("random")
+	assert.Equal(t, ErrUnknownStrategy, err)
+}
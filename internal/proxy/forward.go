@@ -0,0 +1,262 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/client"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultDialTimeout = 10 * time.Second
+
+var (
+	ErrNotProxyRequest   = errors.New("request target is not in absolute or authority form")
+	ErrDestinationDenied = errors.New("destination is not allowed")
+)
+
+// ForwardProxy handles absolute-form requests by forwarding them and CONNECT
+// requests by tunneling bytes to the destination.
+type ForwardProxy struct {
+	// Allow and Deny hold destination rules: a host name, "*.domain", an IP
+	// or a CIDR, each optionally followed by ":port". Deny wins; a non-empty
+	// Allow list rejects everything it doesn't match.
+	Allow       []string
+	Deny        []string
+	Client      *client.Client
+	DialTimeout time.Duration
+
+	// lookupIP resolves destinations; nil means net.LookupIP.
+	lookupIP func(host string) ([]net.IP, error)
+}
+
+func NewForwardProxy() *ForwardProxy {
+	return &ForwardProxy{
+		Client:      newUpstreamClient(),
+		DialTimeout: defaultDialTimeout,
+	}
+}
+
+// IsForwardRequest reports whether r is meant for a forward proxy rather than
+// for this server.
+func IsForwardRequest(r *request.Request) bool {
+	target := r.RequestLine.RequestTarget
+	return r.RequestLine.Method == "CONNECT" ||
+		strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// Handle is a server.Handler.
+func (p *ForwardProxy) Handle(w io.Writer, r *request.Request) {
+	if r.RequestLine.Method == "CONNECT" {
+		p.tunnel(w, r)
+		return
+	}
+
+	u, err := url.Parse(r.RequestLine.RequestTarget)
+	if err != nil || !IsForwardRequest(r) || u.Host == "" {
+		response.WriteError(w, response.BAD_REQUEST, ErrNotProxyRequest)
+		return
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	ip, ok := p.allowed(u.Hostname(), port)
+	if !ok {
+		response.WriteError(w, response.FORBIDDEN, ErrDestinationDenied)
+		return
+	}
+
+	out := r.Clone()
+	removeHopByHop(out.Headers)
+	// the client derives Host from the absolute target
+	delete(out.Headers, "host")
+
+	s := newResponseStreamer(w, r.RequestLine.Method)
+	// dial the address that was checked; resolving again could give another
+	err = p.Client.StreamVia(net.JoinHostPort(ip.String(), port), out, s.handle)
+	if err == nil {
+		return
+	}
+
+	fmt.Printf("Forward error: %v\n", err)
+	if !s.started {
+		response.WriteError(w, upstreamStatus(err), err)
+	}
+}
+
+// tunnel takes over the client connection and splices it with a connection
+// to the CONNECT destination until either side is done.
+func (p *ForwardProxy) tunnel(w io.Writer, r *request.Request) {
+	host, port, err := net.SplitHostPort(r.RequestLine.RequestTarget)
+	if err != nil {
+		response.WriteError(w, response.BAD_REQUEST, ErrNotProxyRequest)
+		return
+	}
+
+	ip, ok := p.allowed(host, port)
+	if !ok {
+		response.WriteError(w, response.FORBIDDEN, ErrDestinationDenied)
+		return
+	}
+
+	hijacker, ok := w.(server.Hijacker)
+	if !ok {
+		response.WriteError(w, response.SERVER_ERROR, server.ErrNotHijackable)
+		return
+	}
+
+	dest, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), port), p.DialTimeout)
+	if err != nil {
+		fmt.Printf("Tunnel error: %v\n", err)
+		response.WriteError(w, upstreamStatus(err), err)
+		return
+	}
+	defer dest.Close()
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		fmt.Printf("Tunnel error: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		return
+	}
+
+	// the client may have sent tunnel bytes right after the CONNECT head
+	if len(buffered) > 0 {
+		if _, err := dest.Write(buffered); err != nil {
+			return
+		}
+	}
+
+	splice(conn, dest)
+}
+
+// splice copies bytes both ways and returns once both directions are done.
+func splice(a net.Conn, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	copyHalf := func(dst net.Conn, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// let the other side see EOF while its data may still be flowing
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+	}
+
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	wg.Wait()
+}
+
+// allowed resolves host and checks it against the rules. It returns the
+// address to dial: one that is allowed, and only when no address host
+// resolves to is denied. Failed lookups are denied.
+func (p *ForwardProxy) allowed(host string, port string) (net.IP, bool) {
+	host = strings.TrimSuffix(strings.ToLower(strings.Trim(host, "[]")), ".")
+
+	ips, err := p.resolve(host)
+	if err != nil || len(ips) == 0 {
+		return nil, false
+	}
+
+	for _, ip := range ips {
+		for _, rule := range p.Deny {
+			if matchRule(rule, host, port, ip) {
+				return nil, false
+			}
+		}
+	}
+
+	for _, ip := range ips {
+		if len(p.Allow) == 0 {
+			return ip, true
+		}
+		for _, rule := range p.Allow {
+			if matchRule(rule, host, port, ip) {
+				return ip, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// resolve returns the addresses of host with IPv4-mapped IPv6 ones
+// unmapped, so rules see them as IPv4.
+func (p *ForwardProxy) resolve(host string) ([]net.IP, error) {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		lookupIP := p.lookupIP
+		if lookupIP == nil {
+			lookupIP = net.LookupIP
+		}
+
+		var err error
+		ips, err = lookupIP(host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, ip := range ips {
+		ips[i] = unmap(ip)
+	}
+	return ips, nil
+}
+
+func unmap(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+func splitRule(rule string) (string, string) {
+	if host, port, err := net.SplitHostPort(rule); err == nil {
+		return host, port
+	}
+	return rule, ""
+}
+
+// matchRule checks one rule against a destination: IP and CIDR rules
+// against the resolved address, name rules against the host name.
+func matchRule(rule string, host string, port string, ip net.IP) bool {
+	ruleHost, rulePort := splitRule(rule)
+	if rulePort != "" && rulePort != port {
+		return false
+	}
+
+	if _, network, err := net.ParseCIDR(ruleHost); err == nil {
+		return network.Contains(ip)
+	}
+
+	if ruleIP := net.ParseIP(strings.Trim(ruleHost, "[]")); ruleIP != nil {
+		return unmap(ruleIP).Equal(ip)
+	}
+
+	ruleHost = strings.TrimSuffix(strings.ToLower(ruleHost), ".")
+	if strings.HasPrefix(ruleHost, "*.") {
+		return strings.HasSuffix(host, ruleHost[1:])
+	}
+
+	return ruleHost == host
+}
@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveEcho(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestForwardProxyAbsoluteForm(t *testing.T) {
+	addr, requests := serveUpstream(t, upstreamReply{
+		reply: "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello",
+	})
+
+	p := NewForwardProxy()
+	r := parseRequest(t, "GET http://"+addr+"/path?q=1 HTTP/1.1\r\n"+
+		"Host: "+addr+"\r\n"+
+		"Proxy-Authorization: Basic c2VjcmV0\r\n"+
+		"Proxy-Connection: keep-alive\r\n"+
+		"\r\n")
+	assert.True(t, IsForwardRequest(r))
+
+	var out bytes.Buffer
+	p.Handle(&out, r)
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(res.Body))
+
+	up := <-requests
+	assert.Equal(t, "/path?q=1", up.RequestLine.RequestTarget)
+	assert.Equal(t, addr, up.Headers["host"])
+	assert.NotContains(t, up.Headers, "proxy-authorization")
+	assert.NotContains(t, up.Headers, "proxy-connection")
+}
+
+type pipeHijacker struct {
+	net.Conn
+	buffered []byte
+}
+
+func (h *pipeHijacker) Hijack() (net.Conn, []byte, error) {
+	return h.Conn, h.buffered, nil
+}
+
+func TestForwardProxyConnect(t *testing.T) {
+	addr := serveEcho(t)
+	p := NewForwardProxy()
+	p.Allow = []string{"127.0.0.1"}
+
+	clientEnd, serverEnd := net.Pipe()
+	defer clientEnd.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer serverEnd.Close()
+		p.Handle(&pipeHijacker{Conn: serverEnd, buffered: []byte("early ")}, parseRequest(t, "CONNECT "+addr+" HTTP/1.1\r\nHost: "+addr+"\r\n\r\n"))
+	}()
+
+	reader := bufio.NewReader(clientEnd)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 Connection Established\r\n", line)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "\r\n", line)
+
+	_, err = clientEnd.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "early ping", string(buf))
+
+	clientEnd.Close()
+	<-done
+}
+
+// fakeLookup resolves the names in hosts and fails for anything else.
+func fakeLookup(hosts map[string][]string) func(string) ([]net.IP, error) {
+	return func(host string) ([]net.IP, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+
+		ips := []net.IP{}
+		for _, addr := range addrs {
+			ips = append(ips, net.ParseIP(addr))
+		}
+		return ips, nil
+	}
+}
+
+func TestForwardProxyConnectThroughServer(t *testing.T) {
+	addr := serveEcho(t)
+	p := NewForwardProxy()
+
+	// Serv doesn't tell which port it got, so pick a free one
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	s, err := server.Serv(port, p.Handle)
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the first tunnel bytes arrive together with the CONNECT head
+	_, err = conn.Write([]byte("CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\n\r\nping"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	head := make([]byte, len("HTTP/1.1 200 Connection Established\r\n\r\n"))
+	_, err = io.ReadFull(reader, head)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 Connection Established\r\n\r\n", string(head))
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	// outlive the server's read deadline
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = conn.Write([]byte("pong"))
+	require.NoError(t, err)
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf))
+}
+
+func TestForwardProxyDestinationRules(t *testing.T) {
+	p := NewForwardProxy()
+	p.Allow = []string{"*.example.com", "10.0.0.0/8:443", "localhost"}
+	p.Deny = []string{"admin.example.com", "10.0.0.1"}
+	p.lookupIP = fakeLookup(map[string][]string{
+		"www.example.com":    {"93.184.216.34"},
+		"admin.example.com":  {"93.184.216.35"},
+		"example.org":        {"93.184.216.36"},
+		"localhost":          {"127.0.0.1"},
+		"sneaky.example.com": {"10.0.0.1"},
+	})
+
+	tests := []struct {
+		host    string
+		port    string
+		allowed bool
+	}{
+		{"www.example.com", "443", true},
+		{"www.example.com.", "443", true},
+		{"admin.example.com", "443", false},
+		{"ADMIN.example.com.", "443", false},
+		{"example.org", "80", false},
+		{"10.1.2.3", "443", true},
+		{"10.1.2.3", "80", false},
+		{"10.0.0.1", "443", false},
+		{"sneaky.example.com", "443", false},
+		{"LOCALHOST", "8080", true},
+		{"missing.example.com", "443", false},
+	}
+
+	for _, tt := range tests {
+		_, allowed := p.allowed(tt.host, tt.port)
+		assert.Equal(t, tt.allowed, allowed, tt.host+":"+tt.port)
+	}
+}
+
+func TestForwardProxyDenyResolvedAddresses(t *testing.T) {
+	lookup := fakeLookup(map[string][]string{
+		"localhost":   {"::1", "127.0.0.1"},
+		"127.1":       {"127.0.0.1"},
+		"0x7f.1":      {"127.0.0.1"},
+		"rebind.test": {"93.184.216.34", "::ffff:127.0.0.1"},
+	})
+
+	tests := []struct {
+		deny string
+		host string
+	}{
+		{"127.0.0.1", "localhost"},
+		{"127.0.0.1", "127.1"},
+		{"127.0.0.1", "0x7f.1"},
+		{"127.0.0.1", "::ffff:127.0.0.1"},
+		{"127.0.0.1", "[::ffff:127.0.0.1]"},
+		{"127.0.0.1", "rebind.test"},
+		{"127.0.0.0/8", "127.1"},
+		{"127.0.0.0/8", "[::ffff:7f00:1]"},
+		{"::ffff:127.0.0.1", "127.0.0.1"},
+		// a lookup failure is no reason to let a request through
+		{"127.0.0.0/8", "unresolvable.test"},
+	}
+
+	for _, tt := range tests {
+		p := NewForwardProxy()
+		p.Deny = []string{tt.deny}
+		p.lookupIP = lookup
+
+		_, allowed := p.allowed(tt.host, "80")
+		assert.False(t, allowed, tt.deny+" "+tt.host)
+	}
+
+	// without the fake lookup the real resolver must not let these through
+	p := NewForwardProxy()
+	p.Deny = []string{"127.0.0.0/8", "::1"}
+	for _, host := range []string{"localhost", "127.1", "0x7f.1", "[::ffff:127.0.0.1]", "nonexistent.invalid"} {
+		_, allowed := p.allowed(host, "80")
+		assert.False(t, allowed, host)
+	}
+}
+
+func TestForwardProxyDialsCheckedAddress(t *testing.T) {
+	addr, requests := serveUpstream(t, upstreamReply{
+		reply: "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok",
+	})
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+
+	// the name only resolves through the proxy's lookup, so a second
+	// resolution when dialing would fail
+	p := NewForwardProxy()
+	p.Allow = []string{"127.0.0.1"}
+	p.lookupIP = fakeLookup(map[string][]string{"upstream.test": {"127.0.0.1"}})
+
+	r := parseRequest(t, "GET http://upstream.test:"+port+"/ HTTP/1.1\r\nHost: upstream.test:"+port+"\r\n\r\n")
+	var out bytes.Buffer
+	p.Handle(&out, r)
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.Equal(t, response.OK, res.StatusLine.StatusCode)
+	assert.Equal(t, "ok", string(res.Body))
+	assert.Equal(t, "upstream.test:"+port, (<-requests).Headers["host"])
+
+	echo := serveEcho(t)
+	_, echoPort, err := net.SplitHostPort(echo)
+	require.NoError(t, err)
+
+	clientEnd, serverEnd := net.Pipe()
+	defer clientEnd.Close()
+	go func() {
+		defer serverEnd.Close()
+		p.Handle(&pipeHijacker{Conn: serverEnd}, parseRequest(t, "CONNECT upstream.test:"+echoPort+" HTTP/1.1\r\nHost: upstream.test:"+echoPort+"\r\n\r\n"))
+	}()
+
+	reader := bufio.NewReader(clientEnd)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 Connection Established\r\n", line)
+}
+
+func TestForwardProxyRejects(t *testing.T) {
+	p := NewForwardProxy()
+	p.Deny = []string{"127.0.0.1"}
+
+	tests := []struct {
+		data   string
+		status response.StatusCode
+	}{
+		{"CONNECT 127.0.0.1:443 HTTP/1.1\r\nHost: 127.0.0.1:443\r\n\r\n", response.FORBIDDEN},
+		{"GET http://127.0.0.1/ HTTP/1.1\r\nHost: 127.0.0.1\r\n\r\n", response.FORBIDDEN},
+		{"GET /local HTTP/1.1\r\nHost: localhost\r\n\r\n", response.BAD_REQUEST},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		p.Handle(&out, parseRequest(t, tt.data))
+
+		res, err := response.ResponseFromReader(&out)
+		require.NoError(t, err)
+		assert.Equal(t, tt.status, res.StatusLine.StatusCode, strings.Fields(tt.data)[1])
+	}
+}
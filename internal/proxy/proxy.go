@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"errors"
+	"httpfromtcp/internal/client"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUpstreamTimeout     = 30 * time.Second
+	defaultUpstreamReadTimeout = 2 * time.Minute
+)
+
+// hopByHopHeaders only make sense for a single connection and are never
+// forwarded.
+var hopByHopHeaders = []string{
+	"connection",
+	"keep-alive",
+	"proxy-connection",
+	"proxy-authenticate",
+	"proxy-authorization",
+	"te",
+	"trailer",
+	"transfer-encoding",
+	"upgrade",
+}
+
+// newUpstreamClient returns the client proxies forward with. Upstreams get
+// Timeout to send the response head; bodies only have to keep arriving, so
+// event streams and large downloads aren't cut off.
+func newUpstreamClient() *client.Client {
+	c := client.NewClient()
+	c.Timeout = defaultUpstreamTimeout
+	c.ReadTimeout = defaultUpstreamReadTimeout
+	return c
+}
+
+// removeHopByHop deletes hop-by-hop headers from h, including the ones
+// listed in its Connection header.
+func removeHopByHop(h headers.Headers) {
+	if val, ok := h.Get("connection"); ok {
+		for _, name := range strings.Split(val, ",") {
+			delete(h, strings.ToLower(strings.TrimSpace(name)))
+		}
+	}
+
+	for _, key := range hopByHopHeaders {
+		delete(h, key)
+	}
+}
+
+// addForwarded records the client and the original host in the
+// X-Forwarded-* and Forwarded headers of out.
+func addForwarded(out *request.Request, r *request.Request) {
+	ip := clientIP(r)
+	host, _ := r.Headers.Get("host")
+
+	if ip != "" {
+		if prior, ok := out.Headers.Get("x-forwarded-for"); ok {
+			out.Headers["x-forwarded-for"] = prior + ", " + ip
+		} else {
+			out.Headers["x-forwarded-for"] = ip
+		}
+	}
+	if host != "" {
+		out.Headers["x-forwarded-host"] = host
+	}
+	out.Headers["x-forwarded-proto"] = "http"
+
+	node := ip
+	if strings.Contains(node, ":") {
+		node = "[" + node + "]"
+	}
+
+	elems := []string{}
+	if node != "" {
+		elems = append(elems, "for="+forwardedValue(node))
+	}
+	if host != "" {
+		elems = append(elems, "host="+forwardedValue(host))
+	}
+	elems = append(elems, "proto=http")
+
+	forwarded := strings.Join(elems, ";")
+	if prior, ok := out.Headers.Get("forwarded"); ok {
+		forwarded = prior + ", " + forwarded
+	}
+	out.Headers["forwarded"] = forwarded
+}
+
+// forwardedValue quotes v unless it is a valid token.
+func forwardedValue(v string) string {
+	for _, c := range v {
+		isAlnum := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !isAlnum && !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+		}
+	}
+	return v
+}
+
+// upstreamStatus picks the status for a failed exchange with an upstream.
+func upstreamStatus(err error) response.StatusCode {
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return response.GATEWAY_TIMEOUT
+	}
+	return response.BAD_GATEWAY
+}
+
+// responseStreamer copies upstream response events to a downstream writer.
+// Bodies without a known length are re-chunked so trailers survive.
+type responseStreamer struct {
+	rw         *response.Writer
+	method     string
+	statusCode response.StatusCode
+	headers    headers.Headers
+	trailers   headers.Headers
+	chunked    bool
+	started    bool
+	// writeErr is set once writing downstream failed, which says nothing
+	// about the upstream
+	writeErr error
+}
+
+func newResponseStreamer(w io.Writer, method string) *responseStreamer {
+	return &responseStreamer{
+		rw:       response.NewWriter(w),
+		method:   method,
+		headers:  headers.NewHeaders(),
+		trailers: headers.NewHeaders(),
+	}
+}
+
+func (s *responseStreamer) handle(ev response.Event) error {
+	if err := s.write(ev); err != nil {
+		s.writeErr = err
+		return err
+	}
+	return nil
+}
+
+func (s *responseStreamer) write(ev response.Event) error {
+	switch ev.Type {
+	case response.EventStatusLine:
+		s.statusCode = ev.StatusLine.StatusCode
+	case response.EventHeader:
+		s.headers.Add(ev.Key, ev.Value)
+	case response.EventHeadersDone:
+		return s.writeHeaders()
+	case response.EventBodyChunk:
+		if s.chunked {
+			_, err := s.rw.WriteChunkedBody(ev.Data)
+			return err
+		}
+		_, err := s.rw.WriteBody(ev.Data)
+		return err
+	case response.EventTrailer:
+		s.trailers.Add(ev.Key, ev.Value)
+	case response.EventComplete:
+		if !s.chunked {
+			return nil
+		}
+		if _, err := s.rw.WriteChunkedBodyDone(len(s.trailers) > 0); err != nil {
+			return err
+		}
+		if len(s.trailers) > 0 {
+			return s.rw.WriteTrailers(s.trailers)
+		}
+	}
+
+	return nil
+}
+
+func (s *responseStreamer) writeHeaders() error {
+	s.started = true
+
+	code := s.statusCode
+	noBody := s.method == "HEAD" || code < 200 || code == 204 || code == 304
+	_, hasTE := s.headers.Get("transfer-encoding")
+	_, hasCL := s.headers.Get("content-length")
+	s.chunked = !noBody && (hasTE || !hasCL)
+
+	trailer, hasTrailer := s.headers.Get("trailer")
+	removeHopByHop(s.headers)
+	if s.chunked {
+		delete(s.headers, "content-length")
+		s.headers["transfer-encoding"] = "chunked"
+		if hasTrailer {
+			s.headers["trailer"] = trailer
+		}
+	}
+	s.headers["connection"] = "close"
+
+	if err := s.rw.WriteStatusLine(code); err != nil {
+		return err
+	}
+	return s.rw.WriteHeaders(s.headers)
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/client"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net/url"
+	"strings"
+)
+
+var ErrWrongUpstream = errors.New("upstream must be an absolute http or https url")
+
+// ReverseProxy forwards requests to a single upstream and streams the
+// responses back.
+type ReverseProxy struct {
+	upstream *url.URL
+	// StripPrefix is removed from request targets before they are appended
+	// to the upstream path.
+	StripPrefix string
+	Client      *client.Client
+}
+
+func NewReverseProxy(upstream string) (*ReverseProxy, error) {
+	u, err := parseUpstream(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReverseProxy{
+		upstream: u,
+		Client:   newUpstreamClient(),
+	}, nil
+}
+
+func parseUpstream(upstream string) (*url.URL, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrWrongUpstream
+	}
+
+	return u, nil
+}
+
+// Handle is a server.Handler.
+func (p *ReverseProxy) Handle(w io.Writer, r *request.Request) {
+	out := outgoing(r, p.upstream, p.StripPrefix)
+	s := newResponseStreamer(w, r.RequestLine.Method)
+
+	err := p.Client.Stream(out, s.handle)
+	if err == nil {
+		return
+	}
+
+	fmt.Printf("Upstream error: %v\n", err)
+	// once the status line went out all that is left is to drop the
+	// connection
+	if !s.started {
+		response.WriteError(w, upstreamStatus(err), err)
+	}
+}
+
+// outgoing builds the request sent to upstream.
+func outgoing(r *request.Request, upstream *url.URL, stripPrefix string) *request.Request {
+	out := r.Clone()
+
+	path := strings.TrimPrefix(r.RequestLine.RequestTarget, stripPrefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	out.RequestLine.RequestTarget = upstream.Scheme + "://" + upstream.Host +
+		strings.TrimSuffix(upstream.Path, "/") + path
+
+	removeHopByHop(out.Headers)
+	addForwarded(out, r)
+	// the client sets the Host header of the upstream
+	delete(out.Headers, "host")
+
+	return out
+}
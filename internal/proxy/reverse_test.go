@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"bytes"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type upstreamReply struct {
+	reply string
+	delay time.Duration
+	// chunks are written after reply, interval apart
+	chunks   []string
+	interval time.Duration
+}
+
+// serveUpstream answers each connection with reply and hands the received
+// requests back.
+func serveUpstream(t *testing.T, reply upstreamReply) (string, <-chan *request.Request) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	requests := make(chan *request.Request, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				r, err := request.RequestFromReader(conn)
+				if err != nil {
+					return
+				}
+				requests <- r
+				time.Sleep(reply.delay)
+				conn.Write([]byte(reply.reply))
+				for _, chunk := range reply.chunks {
+					time.Sleep(reply.interval)
+					if _, err := conn.Write([]byte(chunk)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), requests
+}
+
+func parseRequest(t *testing.T, data string) *request.Request {
+	t.Helper()
+
+	r, err := request.RequestFromReader(strings.NewReader(data))
+	require.NoError(t, err)
+	r.RemoteAddr = "10.0.0.7:51234"
+	return r
+}
+
+func TestReverseProxyForwards(t *testing.T) {
+	addr, requests := serveUpstream(t, upstreamReply{
+		reply: "HTTP/1.1 201 Created\r\nContent-Length: 7\r\nX-Upstream: yes\r\nKeep-Alive: timeout=5\r\n\r\ncreated",
+	})
+
+	p, err := NewReverseProxy("http://" + addr + "/api/")
+	require.NoError(t, err)
+	p.StripPrefix = "/proxy"
+
+	r := parseRequest(t, "POST /proxy/items?id=1 HTTP/1.1\r\n"+
+		"Host: localhost:8888\r\n"+
+		"Connection: keep-alive, X-Secret\r\n"+
+		"X-Secret: hop\r\n"+
+		"X-Forwarded-For: 192.168.1.1\r\n"+
+		"Content-Length: 4\r\n"+
+		"\r\n"+
+		"ping")
+
+	var out bytes.Buffer
+	p.Handle(&out, r)
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.EqualValues(t, 201, res.StatusLine.StatusCode)
+	assert.Equal(t, "Created", res.StatusLine.Reason)
+	assert.Equal(t, "yes", res.Headers["x-upstream"])
+	assert.NotContains(t, res.Headers, "keep-alive")
+	assert.Equal(t, "created", string(res.Body))
+
+	up := <-requests
+	assert.Equal(t, "POST", up.RequestLine.Method)
+	assert.Equal(t, "/api/items?id=1", up.RequestLine.RequestTarget)
+	assert.Equal(t, addr, up.Headers["host"])
+	assert.Equal(t, "ping", string(up.Body))
+	assert.NotContains(t, up.Headers, "x-secret")
+	assert.Equal(t, "192.168.1.1, 10.0.0.7", up.Headers["x-forwarded-for"])
+	assert.Equal(t, "localhost:8888", up.Headers["x-forwarded-host"])
+	assert.Equal(t, "http", up.Headers["x-forwarded-proto"])
+	assert.Equal(t, `for=10.0.0.7;host="localhost:8888";proto=http`, up.Headers["forwarded"])
+}
+
+func TestReverseProxyStreamsChunked(t *testing.T) {
+	addr, _ := serveUpstream(t, upstreamReply{
+		reply: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Content-Length\r\n\r\n" +
+			"6\r\nhello \r\n6\r\nworld!\r\n0\r\nX-Content-Length: 12\r\n\r\n",
+	})
+
+	p, err := NewReverseProxy("http://" + addr)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	p.Handle(&out, parseRequest(t, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.Equal(t, "chunked", res.Headers["transfer-encoding"])
+	assert.Equal(t, "X-Content-Length", res.Headers["trailer"])
+	assert.Equal(t, "hello world!", string(res.Body))
+	assert.Equal(t, "12", res.Trailers["x-content-length"])
+}
+
+func TestReverseProxyUpstreamErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := listener.Addr().String()
+	listener.Close()
+
+	p, err := NewReverseProxy("http://" + closedAddr)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	p.Handle(&out, parseRequest(t, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.Equal(t, response.BAD_GATEWAY, res.StatusLine.StatusCode)
+
+	addr, _ := serveUpstream(t, upstreamReply{
+		reply: "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n",
+		delay: 200 * time.Millisecond,
+	})
+
+	p, err = NewReverseProxy("http://" + addr)
+	require.NoError(t, err)
+	p.Client.Timeout = 50 * time.Millisecond
+
+	out.Reset()
+	p.Handle(&out, parseRequest(t, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+
+	res, err = response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.Equal(t, response.GATEWAY_TIMEOUT, res.StatusLine.StatusCode)
+}
+
+func TestNewReverseProxyWrongUpstream(t *testing.T) {
+	_, err := NewReverseProxy("localhost:9000")
+	assert.Equal(t, ErrWrongUpstream, err)
+}
+
+func TestReverseProxySlowStream(t *testing.T) {
+	var chunks []string
+	for i := 0; i < 8; i++ {
+		chunks = append(chunks, "5\r\nevent\r\n")
+	}
+	chunks = append(chunks, "0\r\n\r\n")
+
+	addr, _ := serveUpstream(t, upstreamReply{
+		reply:    "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nTransfer-Encoding: chunked\r\n\r\n",
+		chunks:   chunks,
+		interval: 50 * time.Millisecond,
+	})
+
+	p, err := NewReverseProxy("http://" + addr)
+	require.NoError(t, err)
+	// the stream takes far longer than Timeout but never stalls for long
+	p.Client.Timeout = 100 * time.Millisecond
+	p.Client.ReadTimeout = time.Second
+
+	var out bytes.Buffer
+	p.Handle(&out, parseRequest(t, "GET /events HTTP/1.1\r\nHost: proxy\r\n\r\n"))
+
+	res, err := response.ResponseFromReader(&out)
+	require.NoError(t, err)
+	assert.Equal(t, response.OK, res.StatusLine.StatusCode)
+	assert.Equal(t, strings.Repeat("event", 8), string(res.Body))
+}
+
+func TestReverseProxyStalledStream(t *testing.T) {
+	addr, _ := serveUpstream(t, upstreamReply{
+		reply:    "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nfirst\r\n",
+		chunks:   []string{"0\r\n\r\n"},
+		interval: time.Second,
+	})
+
+	p, err := NewReverseProxy("http://" + addr)
+	require.NoError(t, err)
+	p.Client.ReadTimeout = 100 * time.Millisecond
+
+	var out bytes.Buffer
+	start := time.Now()
+	p.Handle(&out, parseRequest(t, "GET / HTTP/1.1\r\nHost: proxy\r\n\r\n"))
+	assert.Less(t, time.Since(start), 900*time.Millisecond)
+
+	// the head went out already, so the response is just cut short
+	assert.Contains(t, out.String(), "first")
+	_, err = response.ResponseFromReader(&out)
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+package request
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported content coding")
+	ErrDecodedBodyTooLarge = errors.New("decoded body too large")
+	ErrWrongEncoding       = errors.New("malformed encoded body")
+)
+
+// DecodeBody undoes the codings listed in Content-Encoding, last applied
+// first, and drops the header. maxSize limits the decoded size, guarding
+// against compression bombs; zero means no limit. Nothing is touched when
+// a coding is unsupported.
+func (r *Request) DecodeBody(maxSize int64) error {
+	value, ok := r.Headers.Get("content-encoding")
+	if !ok {
+		return nil
+	}
+
+	var codings []string
+	for _, coding := range strings.Split(value, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		switch coding {
+		case "", "identity":
+		case "gzip", "x-gzip", "deflate":
+			codings = append(codings, coding)
+		default:
+			return ErrUnsupportedEncoding
+		}
+	}
+
+	body := r.Body
+	for i := len(codings) - 1; i >= 0; i-- {
+		var err error
+		body, err = decode(codings[i], body, maxSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.Body = body
+	delete(r.Headers, "content-encoding")
+	if _, ok := r.Headers.Get("content-length"); ok {
+		r.Headers.SetContentLength(int64(len(body)))
+	}
+
+	return nil
+}
+
+func decode(coding string, data []byte, maxSize int64) ([]byte, error) {
+	var dec io.ReadCloser
+	var err error
+
+	switch coding {
+	case "gzip", "x-gzip":
+		dec, err = gzip.NewReader(bytes.NewReader(data))
+	case "deflate":
+		// deflate should be zlib wrapped, but some clients send raw deflate
+		if isZlib(data) {
+			dec, err = zlib.NewReader(bytes.NewReader(data))
+		} else {
+			dec = flate.NewReader(bytes.NewReader(data))
+		}
+	default:
+		return nil, ErrUnsupportedEncoding
+	}
+	if err != nil {
+		return nil, ErrWrongEncoding
+	}
+	defer dec.Close()
+
+	var src io.Reader = dec
+	if maxSize > 0 {
+		src = io.LimitReader(dec, maxSize+1)
+	}
+
+	decoded, err := io.ReadAll(src)
+	if err != nil {
+		return nil, ErrWrongEncoding
+	}
+	if maxSize > 0 && int64(len(decoded)) > maxSize {
+		return nil, ErrDecodedBodyTooLarge
+	}
+
+	return decoded, nil
+}
+
+// isZlib checks for a zlib header: deflate method and a valid check value.
+func isZlib(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
@@ -0,0 +1,148 @@
+package request
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"httpfromtcp/internal/headers"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+		w = fw
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	plain := []byte(strings.Repeat("hello decode ", 100))
+
+	tests := []struct {
+		name           string
+		encoding       string
+		body           []byte
+		maxSize        int64
+		expected       []byte
+		expectedErr    error
+		expectedLength string
+	}{
+		{
+			name:           "Gzip",
+			encoding:       "gzip",
+			body:           encode(t, "gzip", plain),
+			expected:       plain,
+			expectedLength: "1300",
+		},
+		{
+			name:           "Deflate",
+			encoding:       "Deflate",
+			body:           encode(t, "deflate", plain),
+			expected:       plain,
+			expectedLength: "1300",
+		},
+		{
+			name:           "Raw deflate",
+			encoding:       "deflate",
+			body:           encode(t, "raw", plain),
+			expected:       plain,
+			expectedLength: "1300",
+		},
+		{
+			name:           "Stacked codings",
+			encoding:       "deflate, gzip",
+			body:           encode(t, "gzip", encode(t, "deflate", plain)),
+			expected:       plain,
+			expectedLength: "1300",
+		},
+		{
+			name:           "Identity",
+			encoding:       "identity",
+			body:           plain,
+			expected:       plain,
+			expectedLength: "1300",
+		},
+		{
+			name:        "Unsupported",
+			encoding:    "gzip, br",
+			body:        plain,
+			expectedErr: ErrUnsupportedEncoding,
+		},
+		{
+			name:        "Too large",
+			encoding:    "gzip",
+			body:        encode(t, "gzip", plain),
+			maxSize:     1000,
+			expectedErr: ErrDecodedBodyTooLarge,
+		},
+		{
+			name:           "At the limit",
+			encoding:       "gzip",
+			body:           encode(t, "gzip", plain),
+			maxSize:        1300,
+			expected:       plain,
+			expectedLength: "1300",
+		},
+		{
+			name:        "Corrupt",
+			encoding:    "gzip",
+			body:        []byte("not gzip at all"),
+			expectedErr: ErrWrongEncoding,
+		},
+		{
+			name:        "Truncated",
+			encoding:    "gzip",
+			body:        encode(t, "gzip", plain)[:20],
+			expectedErr: ErrWrongEncoding,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := headers.NewHeaders()
+			h["content-encoding"] = tc.encoding
+			h["content-length"] = "1"
+			r := &Request{Headers: h, Body: tc.body}
+
+			err := r.DecodeBody(tc.maxSize)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+				assert.Equal(t, tc.body, r.Body)
+				assert.Equal(t, tc.encoding, r.Headers["content-encoding"])
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, r.Body)
+			assert.NotContains(t, r.Headers, "content-encoding")
+			assert.Equal(t, tc.expectedLength, r.Headers["content-length"])
+		})
+	}
+}
+
+func TestDecodeBodyWithoutEncoding(t *testing.T) {
+	r := &Request{Headers: headers.NewHeaders(), Body: []byte("plain")}
+	require.NoError(t, r.DecodeBody(10))
+	assert.Equal(t, []byte("plain"), r.Body)
+	assert.NotContains(t, r.Headers, "content-length")
+}
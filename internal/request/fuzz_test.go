@@ -0,0 +1,110 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"httpfromtcp/internal/headers"
+)
+
+func withoutFraming(h headers.Headers) headers.Headers {
+	res := headers.NewHeaders()
+	for k, v := range h {
+		if !isFramingHeader(k) {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func addSeeds(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "seeds", "*.http"))
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data, uint8(1))
+		f.Add(data, uint8(7))
+		f.Add(data, uint8(255))
+	}
+}
+
+func FuzzRequestFromReader(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte, bytesPerRead uint8) {
+		reader := &chunkReader{
+			data:            string(data),
+			numBytesPerRead: max(1, int(bytesPerRead)),
+		}
+
+		r, err := RequestFromReader(reader)
+		if err != nil {
+			return
+		}
+
+		if len(r.Body) > len(data) {
+			t.Fatalf("body of %d bytes from %d bytes of input", len(r.Body), len(data))
+		}
+
+		var wire bytes.Buffer
+		if err := r.Write(&wire); err != nil {
+			t.Fatal(err)
+		}
+		again, err := RequestFromReader(&chunkReader{
+			data:            wire.String(),
+			numBytesPerRead: wire.Len(),
+		})
+		if err != nil {
+			t.Fatalf("re-parse of %q: %v", wire.String(), err)
+		}
+
+		if r.RequestLine != again.RequestLine {
+			t.Fatalf("request line %+v != %+v", r.RequestLine, again.RequestLine)
+		}
+		if string(r.Body) != string(again.Body) {
+			t.Fatalf("body %q != %q", r.Body, again.Body)
+		}
+		if fmt.Sprint(withoutFraming(r.Headers)) != fmt.Sprint(withoutFraming(again.Headers)) {
+			t.Fatalf("headers %v != %v", r.Headers, again.Headers)
+		}
+		if fmt.Sprint(r.Trailers) != fmt.Sprint(again.Trailers) {
+			t.Fatalf("trailers %v != %v", r.Trailers, again.Trailers)
+		}
+	})
+}
+
+func FuzzParserFeed(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte, split uint8) {
+		// feeding everything at once and in two pieces must agree
+		whole := NewParser()
+		_, wholeErr := whole.Feed(data)
+
+		pieces := NewParser()
+		at := min(int(split), len(data))
+		n, piecesErr := pieces.Feed(data[:at])
+		if piecesErr == nil {
+			if n > at {
+				t.Fatalf("consumed %d of %d bytes", n, at)
+			}
+			_, piecesErr = pieces.Feed(data[n:])
+		}
+
+		if (wholeErr == nil) != (piecesErr == nil) {
+			t.Fatalf("whole error %v, pieces error %v", wholeErr, piecesErr)
+		}
+		if wholeErr == nil && whole.Done() != pieces.Done() {
+			t.Fatalf("whole done %v, pieces done %v", whole.Done(), pieces.Done())
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package request
+
+import (
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/message"
+	"strings"
+)
+
+const maxRequestLineLength = 8 * 1024
+
+// DefaultMaxBodySize is the MaxBodySize of new parsers.
+const DefaultMaxBodySize = 10 << 20
+
+type EventType int
+
+var (
+	EventRequestLine EventType = 0
+	EventHeader      EventType = 1
+	EventHeadersDone EventType = 2
+	EventBodyChunk   EventType = 3
+	EventTrailer     EventType = 4
+	EventComplete    EventType = 5
+)
+
+var (
+	ErrIncompleteRequest           = errors.New("incomplete request")
+	ErrAmbiguousBodyLength         = errors.New("both content-length and transfer-encoding are set")
+	ErrUnsupportedTransferEncoding = errors.New("unsupported transfer encoding")
+	ErrRequestLineTooLong          = errors.New("request line too long")
+	ErrFieldsTooLarge              = message.ErrFieldsTooLarge
+	ErrBodyTooLarge                = message.ErrBodyTooLarge
+)
+
+// Event is a single piece of a parsed request. RequestLine is set for
+// EventRequestLine, Key and Value for EventHeader and EventTrailer, Data for
+// EventBodyChunk. Data aliases the slice passed to Feed and is only valid
+// until the next call to Feed.
+type Event struct {
+	Type        EventType
+	RequestLine RequestLine
+	Key         string
+	Value       string
+	Data        []byte
+}
+
+// Parser is a push-based request parser: the caller owns the reading and
+// feeds the parser whatever bytes it has, then drains events with Next.
+// Only the request line and the body framing rules are request specific;
+// the rest is message.Parser.
+type Parser struct {
+	// MaxBodySize limits the request body, chunked ones included; zero
+	// means no limit. Content-Length values past it fail right after the
+	// headers.
+	MaxBodySize int64
+
+	msg         *message.Parser
+	requestLine RequestLine
+}
+
+func NewParser() *Parser {
+	p := &Parser{MaxBodySize: DefaultMaxBodySize}
+	p.msg = message.NewParser(message.Kind{
+		ParseStartLine: p.parseRequestLine,
+		Framing:        framing,
+		ErrIncomplete:  ErrIncompleteRequest,
+	})
+	return p
+}
+
+func (p *Parser) Reset() {
+	p.msg.Reset()
+	p.requestLine = RequestLine{}
+}
+
+func (p *Parser) State() ParseState {
+	switch p.msg.State() {
+	case message.StateHeaders:
+		return ParsedRequestLine
+	case message.StateBody:
+		return ParsedHeaders
+	case message.StateTrailers:
+		return ParsedBody
+	case message.StateDone:
+		return Done
+	}
+	return Initialized
+}
+
+func (p *Parser) Done() bool {
+	return p.msg.Done()
+}
+
+// Feed parses as much of data as possible and returns the number of bytes
+// consumed. Unconsumed bytes must be fed again, followed by more input.
+func (p *Parser) Feed(data []byte) (int, error) {
+	p.msg.MaxBodySize = p.MaxBodySize
+	return p.msg.Feed(data)
+}
+
+// Finish tells the parser no more input will follow.
+func (p *Parser) Finish() error {
+	return p.msg.Finish()
+}
+
+// Next returns the oldest event not yet returned.
+func (p *Parser) Next() (Event, bool) {
+	ev, ok := p.msg.Next()
+	if !ok {
+		return Event{}, false
+	}
+
+	out := Event{Type: EventType(ev.Type), Key: ev.Key, Value: ev.Value, Data: ev.Data}
+	if ev.Type == message.EventStartLine {
+		out.RequestLine = p.requestLine
+	}
+	return out, true
+}
+
+func (p *Parser) parseRequestLine(data []byte) (int, error) {
+	n, requestLine, err := parseRequestLine(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		if len(data) > maxRequestLineLength {
+			return 0, ErrRequestLineTooLong
+		}
+		return 0, nil
+	}
+
+	p.requestLine = *requestLine
+	return n, nil
+}
+
+// framing picks the body framing once all headers are known.
+func framing(h headers.Headers) (message.Framing, error) {
+	te, hasTE := h.Get("transfer-encoding")
+	_, hasCL := h.Get("content-length")
+
+	if hasTE && hasCL {
+		return message.Framing{}, ErrAmbiguousBodyLength
+	}
+
+	if hasTE {
+		if !strings.EqualFold(strings.TrimSpace(te), "chunked") {
+			return message.Framing{}, ErrUnsupportedTransferEncoding
+		}
+		return message.Framing{Chunked: true}, nil
+	}
+
+	length, err := h.ContentLength()
+	if err != nil {
+		return message.Framing{}, ErrWrongBodyLength
+	}
+
+	return message.Framing{Length: max(length, 0)}, nil
+}
@@ -0,0 +1,190 @@
+package request
+
+import (
+	"httpfromtcp/internal/message"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserEvents(t *testing.T) {
+	p := NewParser()
+	data := []byte("POST /submit HTTP/1.1\r\n" +
+		"Host: localhost:42069\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n" +
+		"0\r\n" +
+		"X-Sum: 42\r\n" +
+		"\r\n")
+
+	n, err := p.Feed(data)
+	require.NoError(t, err)
+	assert.Equal(t, len(data), n)
+	assert.True(t, p.Done())
+
+	types := []EventType{}
+	for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+		types = append(types, ev.Type)
+
+		switch ev.Type {
+		case EventRequestLine:
+			assert.Equal(t, "POST", ev.RequestLine.Method)
+			assert.Equal(t, "/submit", ev.RequestLine.RequestTarget)
+		case EventBodyChunk:
+			assert.Equal(t, "hello", string(ev.Data))
+		case EventTrailer:
+			assert.Equal(t, "x-sum", ev.Key)
+			assert.Equal(t, "42", ev.Value)
+		}
+	}
+
+	assert.Equal(t, []EventType{
+		EventRequestLine,
+		EventHeader,
+		EventHeader,
+		EventHeadersDone,
+		EventBodyChunk,
+		EventTrailer,
+		EventComplete,
+	}, types)
+}
+
+func TestParserFeedByteByByte(t *testing.T) {
+	p := NewParser()
+	data := "GET /coffee HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 3\r\n\r\nabcGET"
+
+	buf := []byte{}
+	body := []byte{}
+	for i := 0; i < len(data) && !p.Done(); i++ {
+		buf = append(buf, data[i])
+		n, err := p.Feed(buf)
+		require.NoError(t, err)
+		for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+			if ev.Type == EventBodyChunk {
+				body = append(body, ev.Data...)
+			}
+		}
+		buf = buf[n:]
+	}
+
+	assert.True(t, p.Done())
+	assert.Equal(t, "abc", string(body))
+	require.NoError(t, p.Finish())
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+		notes string
+	}{
+		{
+			input: "POST / HTTP/1.1\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n",
+			err:   ErrAmbiguousBodyLength,
+			notes: "Content-Length with Transfer-Encoding",
+		},
+		{
+			input: "POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n",
+			err:   ErrUnsupportedTransferEncoding,
+			notes: "Unsupported transfer encoding",
+		},
+		{
+			input: "POST / HTTP/1.1\r\nContent-Length: -1\r\n\r\n",
+			err:   ErrWrongBodyLength,
+			notes: "Negative content length",
+		},
+		{
+			input: "POST / HTTP/1.1\r\nContent-Length: +3\r\n\r\nabc",
+			err:   ErrWrongBodyLength,
+			notes: "Signed content length",
+		},
+		{
+			input: "POST / HTTP/1.1\r\nContent-Length: 3\r\nContent-Length: 4\r\n\r\nabcd",
+			err:   ErrWrongBodyLength,
+			notes: "Conflicting content lengths",
+		},
+		{
+			input: "GET /a\x00b HTTP/1.1\r\n",
+			err:   ErrWrongTargetFormat,
+			notes: "Control character in target",
+		},
+		{
+			input: "GET /" + strings.Repeat("a", maxRequestLineLength),
+			err:   ErrRequestLineTooLong,
+			notes: "Request line too long",
+		},
+		{
+			input: "GET / HTTP/1.1\r\n" + strings.Repeat("X-Large: value\r\n", message.MaxFieldsBytes/16+1),
+			err:   ErrFieldsTooLarge,
+			notes: "Header section too large",
+		},
+		{
+			input: "POST / HTTP/1.1\r\nContent-Length: 10485761\r\n\r\n",
+			err:   ErrBodyTooLarge,
+			notes: "Content length past MaxBodySize",
+		},
+	}
+
+	for _, tt := range tests {
+		p := NewParser()
+		_, err := p.Feed([]byte(tt.input))
+		assert.Equal(t, tt.err, err, tt.notes)
+	}
+}
+
+func TestParserMaxBodySize(t *testing.T) {
+	p := NewParser()
+	p.MaxBodySize = 8
+
+	_, err := p.Feed([]byte("POST / HTTP/1.1\r\nContent-Length: 8\r\n\r\n12345678"))
+	require.NoError(t, err)
+	assert.True(t, p.Done())
+
+	p.Reset()
+	_, err = p.Feed([]byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\n12345\r\n"))
+	require.NoError(t, err)
+	_, err = p.Feed([]byte("4\r\n6789\r\n0\r\n\r\n"))
+	assert.Equal(t, ErrBodyTooLarge, err)
+
+	p.Reset()
+	p.MaxBodySize = 0
+	_, err = p.Feed([]byte("POST / HTTP/1.1\r\nContent-Length: 10485761\r\n\r\n"))
+	assert.NoError(t, err, "zero means no limit")
+}
+
+func TestParserState(t *testing.T) {
+	p := NewParser()
+	assert.Equal(t, Initialized, p.State())
+
+	steps := []struct {
+		input string
+		state ParseState
+	}{
+		{"POST / HTTP/1.1\r\n", ParsedRequestLine},
+		{"Transfer-Encoding: chunked\r\n\r\n", ParsedHeaders},
+		{"0\r\n", ParsedBody},
+		{"\r\n", Done},
+	}
+	for _, step := range steps {
+		_, err := p.Feed([]byte(step.input))
+		require.NoError(t, err)
+		assert.Equal(t, step.state, p.State(), step.input)
+	}
+	assert.Equal(t, ParseState(3), ParsedBody)
+	assert.Equal(t, ParseState(4), Done)
+}
+
+func TestParserFinish(t *testing.T) {
+	p := NewParser()
+	_, err := p.Feed([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, ErrIncompleteRequest, p.Finish())
+
+	p.Reset()
+	_, err = p.Feed([]byte("GET / HTTP/1.1\r\n\r\n"))
+	require.NoError(t, err)
+	assert.NoError(t, p.Finish())
+}
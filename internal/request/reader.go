@@ -0,0 +1,96 @@
+package request
+
+import (
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/message"
+	"io"
+	"os"
+)
+
+// Reader reads consecutive requests from one connection using a pooled
+// buffer. Bytes that arrive after a request are kept for the next one.
+type Reader struct {
+	bufPtr *[]byte
+	buf    message.Buffer
+	parser *Parser
+}
+
+func NewReader(r io.Reader) *Reader {
+	bufPtr := bufferPool.Get().(*[]byte)
+	p := parserPool.Get().(*Parser)
+	p.Reset()
+
+	rr := &Reader{
+		bufPtr: bufPtr,
+		parser: p,
+	}
+	rr.buf.Reset(r, *bufPtr)
+	return rr
+}
+
+// Buffered returns the bytes read but not parsed yet. The slice is only
+// valid until the next call to ReadRequest or Release.
+func (rr *Reader) Buffered() []byte {
+	return rr.buf.Bytes()
+}
+
+// Release returns the buffer and the parser to their pools; rr can't be used
+// afterwards.
+func (rr *Reader) Release() {
+	if rr.bufPtr == nil {
+		return
+	}
+
+	// grown buffers are only kept while they stay reasonably small
+	if buf := rr.buf.Buf(); len(buf) <= maxPooledBufferSize {
+		*rr.bufPtr = buf
+		bufferPool.Put(rr.bufPtr)
+	}
+	parserPool.Put(rr.parser)
+
+	rr.buf = message.Buffer{}
+	rr.bufPtr = nil
+	rr.parser = nil
+}
+
+func (rr *Reader) ReadRequest() (*Request, error) {
+	p := rr.parser
+	p.Reset()
+
+	r := Request{
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
+	}
+
+	for {
+		n, err := p.Feed(rr.buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		// body chunks alias buf, so drain events before reusing it
+		for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+			r.apply(ev)
+		}
+		rr.buf.Consume(n)
+
+		if p.Done() {
+			return &r, nil
+		}
+
+		if rr.buf.EOF() {
+			if err := p.Finish(); err != nil {
+				return nil, err
+			}
+			return &r, nil
+		}
+
+		if err := rr.buf.Fill(); err != nil {
+			if !errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, err
+			}
+			rr.buf.SetEOF()
+		}
+	}
+}
@@ -4,23 +4,40 @@ import (
 	"bytes"
 	"errors"
 	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/message"
 	"io"
-	"os"
 	"regexp"
-	"strconv"
 	"strings"
+	"sync"
 )
 
 const lineSeparator = "\r\n"
 const partsSeparator = " "
-const bufferSize = 8
+const bufferSize = 4096
+const maxPooledBufferSize = 64 * 1024
 
 type ParseState int
 
 var Initialized ParseState = 0
 var ParsedRequestLine ParseState = 1
 var ParsedHeaders ParseState = 2
-var Done ParseState = 3
+var ParsedBody ParseState = 3
+var Done ParseState = 4
+
+var methodReg = regexp.MustCompile("^[A-Z]+$")
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, bufferSize)
+		return &buf
+	},
+}
+
+var parserPool = sync.Pool{
+	New: func() any {
+		return NewParser()
+	},
+}
 
 var (
 	ErrEmptyRequestLine   = errors.New("empty request line")
@@ -28,7 +45,7 @@ var (
 	ErrWrongVersionFormat = errors.New("wrong version format")
 	ErrWrongTargetFormat  = errors.New("wrong target format")
 	ErrWrongMethodFormat  = errors.New("wrong method format")
-	ErrWrongBodyLength    = errors.New("wrong body length")
+	ErrWrongBodyLength    = message.ErrWrongBodyLength
 )
 
 type RequestLine struct {
@@ -38,10 +55,16 @@ type RequestLine struct {
 }
 
 type Request struct {
-	state       ParseState
 	RequestLine RequestLine
 	Headers     headers.Headers
 	Body        []byte
+	Trailers    headers.Headers
+	// RemoteAddr is the address of the client, set by the server.
+	RemoteAddr string
+
+	// field names in the order they were received, kept by Write
+	headerOrder  []string
+	trailerOrder []string
 }
 
 func parseRequestLine(data []byte) (int, *RequestLine, error) {
@@ -66,6 +89,11 @@ func parseRequestLine(data []byte) (int, *RequestLine, error) {
 		return 0, nil, err
 	}
 
+	target, err := parseTarget(parts[1])
+	if err != nil {
+		return 0, nil, err
+	}
+
 	version, err := parseVersion(parts[2])
 	if err != nil {
 		return 0, nil, err
@@ -73,84 +101,64 @@ func parseRequestLine(data []byte) (int, *RequestLine, error) {
 
 	return idx + 2, &RequestLine{
 		HttpVersion:   version,
-		RequestTarget: parts[1],
+		RequestTarget: target,
 		Method:        method,
 	}, nil
 }
 
-func (r *Request) parse(data []byte, eof bool) (int, error) {
-	if r.state == Done {
-		return 0, nil
+func (r *Request) apply(ev Event) {
+	switch ev.Type {
+	case EventRequestLine:
+		r.RequestLine = ev.RequestLine
+	case EventHeader:
+		r.headerOrder = addOrdered(r.headerOrder, r.Headers, ev.Key)
+		r.Headers.Add(ev.Key, ev.Value)
+	case EventBodyChunk:
+		r.Body = append(r.Body, ev.Data...)
+	case EventTrailer:
+		r.trailerOrder = addOrdered(r.trailerOrder, r.Trailers, ev.Key)
+		r.Trailers.Add(ev.Key, ev.Value)
 	}
+}
 
-	// parse request line
-	if r.state == Initialized {
-		n, requestLine, err := parseRequestLine(data)
-		if err != nil {
-			return 0, err
-		}
-
-		if n == 0 {
-			return n, nil
-		}
-
-		r.RequestLine = *requestLine
-		r.state = ParsedRequestLine
-		return n, nil
+func addOrdered(order []string, h headers.Headers, key string) []string {
+	if _, ok := h[key]; ok {
+		return order
 	}
+	return append(order, key)
+}
 
-	// parse headers
-	if r.state == ParsedRequestLine {
-		n, done, err := r.Headers.Parse(data)
-
-		if err != nil {
-			return n, err
-		}
-
-		if done {
-			r.state = ParsedHeaders
-		}
-
-		return n, nil
+// IsIdempotent reports whether repeating a request with method has the same
+// effect as sending it once, which makes it safe to retry.
+func IsIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
 	}
-
-	// parse body
-	if r.state == ParsedHeaders {
-		val, ok := r.Headers.Get("content-length")
-		if !ok {
-			r.state = Done
-			return 0, nil
-		}
-
-		length, err := strconv.Atoi(val)
-		if err != nil {
-			return 0, err
-		}
-
-		l := len(data)
-		if eof {
-			if length != l {
-				return 0, ErrWrongBodyLength
-			}
-			r.state = Done
-			r.Body = data[:]
-			return l, nil
-		} else {
-			return 0, nil
-		}
-	}
-
-	return 0, errors.New("uknown parse error")
+	return false
 }
 
 func parseMethod(method string) (string, error) {
-	reg := regexp.MustCompile("^[A-Z]+$")
-	if reg.MatchString(method) {
+	if methodReg.MatchString(method) {
 		return method, nil
 	}
 	return "", ErrWrongMethodFormat
 }
 
+func parseTarget(target string) (string, error) {
+	if target == "" {
+		return "", ErrWrongTargetFormat
+	}
+
+	for i := 0; i < len(target); i++ {
+		if target[i] <= ' ' || target[i] == 0x7f {
+			return "", ErrWrongTargetFormat
+		}
+	}
+
+	return target, nil
+}
+
 func parseVersion(version string) (string, error) {
 	if version != "HTTP/1.1" {
 		return "", ErrWrongVersionFormat
@@ -160,57 +168,8 @@ func parseVersion(version string) (string, error) {
 }
 
 func RequestFromReader(reader io.Reader) (*Request, error) {
-	buf := make([]byte, bufferSize)
-	readToIndex := 0
-
-	r := Request{
-		state:   Initialized,
-		Headers: headers.NewHeaders(),
-	}
-
-	eof := false
-
-	for r.state != Done {
-		if eof {
-			break
-		}
-		// buffer is full, twice buffer size and copy
-		if readToIndex >= len(buf)-1 {
-			bufLen := max(bufferSize, len(buf)*2)
-			tmpBuff := make([]byte, bufLen)
-			copy(tmpBuff, buf)
-			buf = tmpBuff
-		}
-
-		n, err := reader.Read(buf[readToIndex:])
-
-		if err != nil {
-			if errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded) {
-				// r.state = Done
-				eof = true
-			} else {
-				return nil, err
-			}
-		}
-
-		readToIndex += n
-
-		n, err = r.parse(buf[:readToIndex], eof)
-
-		if err != nil {
-			return nil, err
-		}
-
-		if n == 0 {
-			continue
-		}
-
-		l := len(buf[n:])
-		tmpBuff := make([]byte, l)
-		copy(tmpBuff, buf[n:])
-		buf = tmpBuff
-		readToIndex -= n
-	}
+	rr := NewReader(reader)
+	defer rr.Release()
 
-	return &r, nil
+	return rr.ReadRequest()
 }
@@ -0,0 +1,139 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// legacyRequestFromReader is the reader loop RequestFromReader used before
+// buffers were pooled; it is kept to compare allocations against.
+func legacyRequestFromReader(reader io.Reader) (*Request, error) {
+	const legacyBufferSize = 8
+
+	buf := make([]byte, legacyBufferSize)
+	readToIndex := 0
+
+	p := NewParser()
+	r := Request{
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
+	}
+
+	eof := false
+
+	for !p.Done() {
+		if eof {
+			if err := p.Finish(); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if readToIndex >= len(buf)-1 {
+			bufLen := max(legacyBufferSize, len(buf)*2)
+			tmpBuff := make([]byte, bufLen)
+			copy(tmpBuff, buf)
+			buf = tmpBuff
+		}
+
+		n, err := reader.Read(buf[readToIndex:])
+
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+				eof = true
+			} else {
+				return nil, err
+			}
+		}
+
+		readToIndex += n
+
+		n, err = p.Feed(buf[:readToIndex])
+
+		if err != nil {
+			return nil, err
+		}
+
+		for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+			r.apply(ev)
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		l := len(buf[n:])
+		tmpBuff := make([]byte, l)
+		copy(tmpBuff, buf[n:])
+		buf = tmpBuff
+		readToIndex -= n
+	}
+
+	return &r, nil
+}
+
+func newBenchRequest(bodySize int) string {
+	return "POST /submit HTTP/1.1\r\n" +
+		"Host: localhost:42069\r\n" +
+		"User-Agent: curl/7.81.0\r\n" +
+		"Accept: */*\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: " + strconv.Itoa(bodySize) + "\r\n" +
+		"\r\n" +
+		strings.Repeat("x", bodySize)
+}
+
+func benchmarkReader(b *testing.B, data string, bytesPerRead int, read func(io.Reader) (*Request, error)) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		reader := &chunkReader{
+			data:            data,
+			numBytesPerRead: bytesPerRead,
+		}
+		_, err := read(reader)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRequestFromReader(b *testing.B) {
+	for _, bodySize := range []int{0, 1024, 64 * 1024} {
+		for _, bytesPerRead := range []int{16, 512, 64 * 1024} {
+			data := newBenchRequest(bodySize)
+			name := fmt.Sprintf("body=%d/read=%d", bodySize, bytesPerRead)
+			b.Run(name, func(b *testing.B) {
+				benchmarkReader(b, data, bytesPerRead, RequestFromReader)
+			})
+		}
+	}
+}
+
+func BenchmarkLegacyRequestFromReader(b *testing.B) {
+	benchmarkReader(b, newBenchRequest(1024), 512, legacyRequestFromReader)
+}
+
+func BenchmarkParserFeed(b *testing.B) {
+	data := []byte(newBenchRequest(1024))
+	p := NewParser()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		p.Reset()
+		_, err := p.Feed(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, ok := p.Next(); ok; _, ok = p.Next() {
+		}
+	}
+}
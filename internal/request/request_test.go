@@ -2,6 +2,7 @@ package request
 
 import (
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -138,3 +139,111 @@ func TestBodyParse(t *testing.T) {
 	_, err = RequestFromReader(reader)
 	require.Error(t, err)
 }
+
+func TestChunkedBodyParse(t *testing.T) {
+	reader := &chunkReader{
+		data: "POST /submit HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"6\r\nhello \r\n" +
+			"6\r\nworld!\r\n" +
+			"0\r\n" +
+			"X-Content-Length: 12\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "hello world!", string(r.Body))
+	assert.Equal(t, "12", r.Trailers["x-content-length"])
+}
+
+func TestRequestLargerThanBuffer(t *testing.T) {
+	value := strings.Repeat("v", bufferSize*2)
+	reader := &chunkReader{
+		data: "GET / HTTP/1.1\r\n" +
+			"X-Large: " + value + "\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"hello",
+		numBytesPerRead: 1000,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, value, r.Headers["x-large"])
+	assert.Equal(t, "hello", string(r.Body))
+}
+
+func TestRequestWrite(t *testing.T) {
+	tests := []struct {
+		data  string
+		wire  string
+		notes string
+	}{
+		{
+			data:  "GET / HTTP/1.1\r\nUser-Agent: curl/7.81.0\r\nHost: localhost:42069\r\n\r\n",
+			wire:  "GET / HTTP/1.1\r\nuser-agent: curl/7.81.0\r\nhost: localhost:42069\r\n\r\n",
+			notes: "No body",
+		},
+		{
+			data:  "POST /submit HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 13\r\n\r\nhello world!\n",
+			wire:  "POST /submit HTTP/1.1\r\nhost: localhost:42069\r\ncontent-length: 13\r\n\r\nhello world!\n",
+			notes: "Content-Length body",
+		},
+		{
+			data: "POST /submit HTTP/1.1\r\nHost: localhost:42069\r\nTransfer-Encoding: chunked\r\n\r\n" +
+				"6\r\nhello \r\n6\r\nworld!\r\n0\r\nX-Content-Length: 12\r\n\r\n",
+			wire: "POST /submit HTTP/1.1\r\nhost: localhost:42069\r\ntransfer-encoding: chunked\r\n\r\n" +
+				"c\r\nhello world!\r\n0\r\nx-content-length: 12\r\n\r\n",
+			notes: "Chunked body with trailers",
+		},
+	}
+
+	for _, tt := range tests {
+		r, err := RequestFromReader(&chunkReader{data: tt.data, numBytesPerRead: 3})
+		require.NoError(t, err, tt.notes)
+
+		var b strings.Builder
+		require.NoError(t, r.Write(&b), tt.notes)
+		assert.Equal(t, tt.wire, b.String(), tt.notes)
+
+		again, err := RequestFromReader(&chunkReader{data: b.String(), numBytesPerRead: 3})
+		require.NoError(t, err, tt.notes)
+		assert.Equal(t, r.RequestLine, again.RequestLine, tt.notes)
+		assert.Equal(t, r.Body, again.Body, tt.notes)
+		assert.Equal(t, r.Trailers, again.Trailers, tt.notes)
+	}
+}
+
+func TestRequestWriteAddedHeaders(t *testing.T) {
+	r, err := RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nX-B: 1\r\nHost: example.com\r\nX-A: 2\r\n\r\n"))
+	require.NoError(t, err)
+
+	delete(r.Headers, "host")
+	r.Headers["x-forwarded-for"] = "127.0.0.1"
+	r.Headers["accept"] = "*/*"
+
+	var b strings.Builder
+	require.NoError(t, r.Clone().Write(&b))
+	assert.Equal(t, "GET / HTTP/1.1\r\nx-b: 1\r\nx-a: 2\r\naccept: */*\r\nx-forwarded-for: 127.0.0.1\r\n\r\n", b.String())
+}
+
+func TestRequestClone(t *testing.T) {
+	reader := &chunkReader{
+		data:            "POST /submit HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello",
+		numBytesPerRead: 3,
+	}
+	r, err := RequestFromReader(reader)
+	require.NoError(t, err)
+
+	clone := r.Clone()
+	assert.Equal(t, r, clone)
+
+	clone.Headers["host"] = "example.com"
+	clone.Body[0] = 'j'
+	assert.Equal(t, "localhost:42069", r.Headers["host"])
+	assert.Equal(t, "hello", string(r.Body))
+}
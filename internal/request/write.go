@@ -0,0 +1,134 @@
+package request
+
+import (
+	"bufio"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Write writes r in HTTP/1.1 wire format. Received fields keep their order;
+// fields set on the maps directly have none and follow sorted by key.
+// Framing headers are recomputed: the body is chunked when r has trailers or
+// was received chunked, and sent with Content-Length otherwise.
+func (r *Request) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	_, err := fmt.Fprintf(bw, "%s %s HTTP/%s\r\n",
+		r.RequestLine.Method, r.RequestLine.RequestTarget, r.RequestLine.HttpVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFields(bw, r.Headers, r.headerOrder); err != nil {
+		return err
+	}
+
+	if r.isChunked() {
+		if _, err := bw.WriteString("transfer-encoding: chunked\r\n\r\n"); err != nil {
+			return err
+		}
+
+		if len(r.Body) > 0 {
+			if _, err := fmt.Fprintf(bw, "%x\r\n", len(r.Body)); err != nil {
+				return err
+			}
+			if _, err := bw.Write(r.Body); err != nil {
+				return err
+			}
+			if _, err := bw.WriteString(lineSeparator); err != nil {
+				return err
+			}
+		}
+
+		if _, err := bw.WriteString("0\r\n"); err != nil {
+			return err
+		}
+		if err := writeFields(bw, r.Trailers, r.trailerOrder); err != nil {
+			return err
+		}
+		if _, err := bw.WriteString(lineSeparator); err != nil {
+			return err
+		}
+
+		return bw.Flush()
+	}
+
+	_, hasCL := r.Headers.Get("content-length")
+	if hasCL || len(r.Body) > 0 {
+		if _, err := fmt.Fprintf(bw, "content-length: %d\r\n", len(r.Body)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := bw.WriteString(lineSeparator); err != nil {
+		return err
+	}
+
+	if _, err := bw.Write(r.Body); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func (r *Request) Clone() *Request {
+	clone := &Request{
+		RequestLine:  r.RequestLine,
+		Headers:      maps.Clone(r.Headers),
+		Trailers:     maps.Clone(r.Trailers),
+		RemoteAddr:   r.RemoteAddr,
+		headerOrder:  slices.Clone(r.headerOrder),
+		trailerOrder: slices.Clone(r.trailerOrder),
+	}
+
+	if r.Body != nil {
+		clone.Body = slices.Clone(r.Body)
+	}
+
+	return clone
+}
+
+func (r *Request) isChunked() bool {
+	if len(r.Trailers) > 0 {
+		return true
+	}
+
+	te, ok := r.Headers.Get("transfer-encoding")
+	return ok && strings.EqualFold(strings.TrimSpace(te), "chunked")
+}
+
+func isFramingHeader(key string) bool {
+	return key == "content-length" || key == "transfer-encoding"
+}
+
+func writeFields(w *bufio.Writer, h headers.Headers, order []string) error {
+	keys := make([]string, 0, len(h))
+	for _, key := range order {
+		if _, ok := h[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(h)) {
+		if !slices.Contains(order, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if isFramingHeader(key) {
+			continue
+		}
+
+		for _, value := range h.Lines(key) {
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
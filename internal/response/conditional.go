@@ -0,0 +1,153 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"httpfromtcp/internal/headers"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the IMF-fixdate form of an HTTP-date.
+const TimeFormat = headers.TimeFormat
+
+// ContentETag returns a strong entity tag derived from the content itself.
+func ContentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`
+}
+
+// FileETag returns an entity tag built from a file's size and modification
+// time. Modification times have nanosecond resolution, so the tag is strong
+// unless weak is set.
+func FileETag(size int64, modTime time.Time, weak bool) string {
+	tag := `"` + strconv.FormatInt(modTime.UnixNano(), 36) + "-" + strconv.FormatInt(size, 36) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// CheckPreconditions evaluates If-Match, If-Unmodified-Since, If-None-Match
+// and If-Modified-Since in the order of RFC 9110 section 13.2.2 for a
+// representation with the given validators, which may be empty or zero.
+// It returns OK when the request should be served normally, NOT_MODIFIED
+// or PRECONDITION_FAILED otherwise.
+func CheckPreconditions(method string, h headers.Headers, etag string, lastModified time.Time) StatusCode {
+	if value, ok := h.Get("if-match"); ok {
+		if !matchETag(value, etag, true) {
+			return PRECONDITION_FAILED
+		}
+	} else if value, ok := h.Get("if-unmodified-since"); ok {
+		date, err := headers.ParseTime(value)
+		if err == nil && !lastModified.IsZero() && lastModified.Truncate(time.Second).After(date) {
+			return PRECONDITION_FAILED
+		}
+	}
+
+	safe := method == "GET" || method == "HEAD"
+
+	if value, ok := h.Get("if-none-match"); ok {
+		if matchETag(value, etag, false) {
+			if safe {
+				return NOT_MODIFIED
+			}
+			return PRECONDITION_FAILED
+		}
+	} else if value, ok := h.Get("if-modified-since"); ok && safe {
+		date, err := headers.ParseTime(value)
+		// dates in the future are bogus and ignored
+		if err == nil && !lastModified.IsZero() && !date.After(time.Now()) && !lastModified.Truncate(time.Second).After(date) {
+			return NOT_MODIFIED
+		}
+	}
+
+	return OK
+}
+
+// IfRange reports whether a Range header may be honoured: If-Range is
+// absent, names the current strong etag, or equals lastModified exactly.
+func IfRange(h headers.Headers, etag string, lastModified time.Time) bool {
+	value, ok := h.Get("if-range")
+	if !ok {
+		return true
+	}
+
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "W/") {
+		tags, ok := parseETags(value)
+		return ok && len(tags) == 1 && strongMatch(tags[0], etag)
+	}
+
+	date, err := headers.ParseTime(value)
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+	return date.Equal(lastModified.Truncate(time.Second))
+}
+
+// matchETag checks a If-Match or If-None-Match value against etag. The
+// wildcard matches any existing representation.
+func matchETag(value string, etag string, strong bool) bool {
+	if strings.TrimSpace(value) == "*" {
+		return etag != ""
+	}
+
+	tags, ok := parseETags(value)
+	if !ok {
+		return false
+	}
+
+	for _, tag := range tags {
+		if strong && strongMatch(tag, etag) {
+			return true
+		}
+		if !strong && weakMatch(tag, etag) {
+			return true
+		}
+	}
+	return false
+}
+
+func strongMatch(a string, b string) bool {
+	return a != "" && a == b && !strings.HasPrefix(a, "W/")
+}
+
+func weakMatch(a string, b string) bool {
+	return a != "" && b != "" && strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
+
+// parseETags splits a list of entity tags. Commas may appear inside the
+// quotes, so the list is scanned rather than split.
+func parseETags(value string) ([]string, bool) {
+	var tags []string
+
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if value == "" {
+			return tags, true
+		}
+
+		start := 0
+		if strings.HasPrefix(value, "W/") {
+			start = 2
+		}
+		if len(value) <= start || value[start] != '"' {
+			return nil, false
+		}
+
+		end := strings.IndexByte(value[start+1:], '"')
+		if end < 0 {
+			return nil, false
+		}
+		end += start + 2
+
+		tags = append(tags, value[:end])
+		value = value[end:]
+
+		if rest := strings.TrimLeft(value, " \t"); rest != "" && rest[0] != ',' {
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package response
+
+import (
+	"httpfromtcp/internal/headers"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETags(t *testing.T) {
+	assert.Equal(t, ContentETag([]byte("a")), ContentETag([]byte("a")))
+	assert.NotEqual(t, ContentETag([]byte("a")), ContentETag([]byte("b")))
+	assert.Regexp(t, `^"[A-Za-z0-9_-]+"$`, ContentETag([]byte("a")))
+
+	modTime := time.Unix(1700000000, 5)
+	assert.Equal(t, `"cwyvpelgpsed-a"`, FileETag(10, modTime, false))
+	assert.Equal(t, `W/"cwyvpelgpsed-a"`, FileETag(10, modTime, true))
+	assert.NotEqual(t, FileETag(10, modTime, false), FileETag(10, modTime.Add(1), false))
+}
+
+func TestParseETags(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		ok       bool
+	}{
+		{`"a"`, []string{`"a"`}, true},
+		{`"a", W/"b",  "c,d"`, []string{`"a"`, `W/"b"`, `"c,d"`}, true},
+		{``, nil, true},
+		{`a`, nil, false},
+		{`"a`, nil, false},
+		{`W/a`, nil, false},
+		{`"a" "b"`, nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			tags, ok := parseETags(tc.value)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, tags)
+		})
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	lastModified := time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	date := lastModified.Format(TimeFormat)
+	later := lastModified.Add(time.Minute).Format(TimeFormat)
+	earlier := lastModified.Add(-time.Minute).Format(TimeFormat)
+	future := time.Now().Add(time.Hour).UTC().Format(TimeFormat)
+
+	tests := []struct {
+		name     string
+		method   string
+		fields   map[string]string
+		etag     string
+		expected StatusCode
+	}{
+		{"No conditions", "GET", nil, `"v1"`, OK},
+		{"If-None-Match on unsafe method", "PUT", map[string]string{"if-none-match": `"v1"`}, `"v1"`, PRECONDITION_FAILED},
+		{"If-None-Match wildcard without representation", "PUT", map[string]string{"if-none-match": "*"}, "", OK},
+		{"If-Match wildcard without representation", "PUT", map[string]string{"if-match": "*"}, "", PRECONDITION_FAILED},
+		{"If-Modified-Since later", "GET", map[string]string{"if-modified-since": later}, `"v1"`, NOT_MODIFIED},
+		{"If-Modified-Since earlier", "HEAD", map[string]string{"if-modified-since": earlier}, `"v1"`, OK},
+		{"If-Modified-Since in the future", "GET", map[string]string{"if-modified-since": future}, `"v1"`, OK},
+		{"If-Modified-Since in RFC 850 form", "GET", map[string]string{"if-modified-since": "Friday, 01-Mar-24 12:00:00 GMT"}, `"v1"`, NOT_MODIFIED},
+		{"If-Modified-Since in asctime form", "GET", map[string]string{"if-modified-since": "Fri Mar  1 11:59:00 2024"}, `"v1"`, OK},
+		{"If-Modified-Since malformed", "GET", map[string]string{"if-modified-since": "yesterday"}, `"v1"`, OK},
+		{"If-Modified-Since on unsafe method", "POST", map[string]string{"if-modified-since": date}, `"v1"`, OK},
+		{"If-Unmodified-Since malformed", "PUT", map[string]string{"if-unmodified-since": "yesterday"}, `"v1"`, OK},
+		{"If-Unmodified-Since same second", "PUT", map[string]string{"if-unmodified-since": date}, `"v1"`, OK},
+		{"Weak If-None-Match", "GET", map[string]string{"if-none-match": `W/"v1"`}, `"v1"`, NOT_MODIFIED},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := headers.NewHeaders()
+			for k, v := range tc.fields {
+				h[k] = v
+			}
+			assert.Equal(t, tc.expected, CheckPreconditions(tc.method, h, tc.etag, lastModified))
+		})
+	}
+}
+
+func TestIfRange(t *testing.T) {
+	lastModified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		etag     string
+		expected bool
+	}{
+		{"Strong match", `"v1"`, `"v1"`, true},
+		{"Different tag", `"v2"`, `"v1"`, false},
+		{"Weak tag", `W/"v1"`, `W/"v1"`, false},
+		{"Several tags", `"v1", "v2"`, `"v1"`, false},
+		{"Same date", lastModified.Format(TimeFormat), `"v1"`, true},
+		{"Other date", lastModified.Add(time.Second).Format(TimeFormat), `"v1"`, false},
+		{"Garbage", "nope", `"v1"`, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := headers.NewHeaders()
+			h["if-range"] = tc.value
+			assert.Equal(t, tc.expected, IfRange(h, tc.etag, lastModified))
+		})
+	}
+
+	assert.True(t, IfRange(headers.NewHeaders(), `"v1"`, lastModified))
+}
@@ -0,0 +1,215 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/message"
+	"strconv"
+	"strings"
+)
+
+const lineSeparator = "\r\n"
+const maxStatusLineLength = 8 * 1024
+
+type ParseState int
+
+var (
+	Initialized      ParseState = 0
+	ParsedStatusLine ParseState = 1
+	ParsedHeaders    ParseState = 2
+	ParsedBody       ParseState = 3
+	Done             ParseState = 4
+)
+
+type EventType int
+
+var (
+	EventStatusLine  EventType = 0
+	EventHeader      EventType = 1
+	EventHeadersDone EventType = 2
+	EventBodyChunk   EventType = 3
+	EventTrailer     EventType = 4
+	EventComplete    EventType = 5
+)
+
+var (
+	ErrWrongStatusLine    = errors.New("wrong status line")
+	ErrWrongVersionFormat = errors.New("wrong version format")
+	ErrWrongStatusCode    = errors.New("wrong status code")
+	ErrWrongBodyLength    = message.ErrWrongBodyLength
+	ErrStatusLineTooLong  = errors.New("status line too long")
+	ErrFieldsTooLarge     = message.ErrFieldsTooLarge
+	ErrIncompleteResponse = errors.New("incomplete response")
+)
+
+type StatusLine struct {
+	HttpVersion string
+	StatusCode  StatusCode
+	Reason      string
+}
+
+// Event is a single piece of a parsed response. StatusLine is set for
+// EventStatusLine, Key and Value for EventHeader and EventTrailer, Data for
+// EventBodyChunk. Data aliases the slice passed to Feed and is only valid
+// until the next call to Feed.
+type Event struct {
+	Type       EventType
+	StatusLine StatusLine
+	Key        string
+	Value      string
+	Data       []byte
+}
+
+// Parser is a push-based response parser, the counterpart of request.Parser.
+// Only the status line and the body framing rules are response specific;
+// the rest is message.Parser.
+type Parser struct {
+	msg        *message.Parser
+	method     string
+	statusLine StatusLine
+}
+
+// NewParser creates a parser for the response to a request with the given
+// method; responses to HEAD never have a body. The method may be empty when
+// it is not known.
+func NewParser(method string) *Parser {
+	p := &Parser{method: method}
+	p.msg = message.NewParser(message.Kind{
+		ParseStartLine: p.parseStatusLine,
+		Framing:        p.framing,
+		ErrIncomplete:  ErrIncompleteResponse,
+	})
+	return p
+}
+
+func (p *Parser) Reset(method string) {
+	p.msg.Reset()
+	p.method = method
+	p.statusLine = StatusLine{}
+}
+
+func (p *Parser) State() ParseState {
+	switch p.msg.State() {
+	case message.StateHeaders:
+		return ParsedStatusLine
+	case message.StateBody:
+		return ParsedHeaders
+	case message.StateTrailers:
+		return ParsedBody
+	case message.StateDone:
+		return Done
+	}
+	return Initialized
+}
+
+func (p *Parser) Done() bool {
+	return p.msg.Done()
+}
+
+// CloseDelimited reports whether the body ends only when the connection is
+// closed, which makes the connection unusable for further responses.
+func (p *Parser) CloseDelimited() bool {
+	return p.msg.CloseDelimited()
+}
+
+// Feed parses as much of data as possible and returns the number of bytes
+// consumed. Unconsumed bytes must be fed again, followed by more input.
+func (p *Parser) Feed(data []byte) (int, error) {
+	return p.msg.Feed(data)
+}
+
+// Finish tells the parser the connection was closed, which ends a
+// close-delimited body.
+func (p *Parser) Finish() error {
+	return p.msg.Finish()
+}
+
+// Next returns the oldest event not yet returned.
+func (p *Parser) Next() (Event, bool) {
+	ev, ok := p.msg.Next()
+	if !ok {
+		return Event{}, false
+	}
+
+	out := Event{Type: EventType(ev.Type), Key: ev.Key, Value: ev.Value, Data: ev.Data}
+	if ev.Type == message.EventStartLine {
+		out.StatusLine = p.statusLine
+	}
+	return out, true
+}
+
+func (p *Parser) parseStatusLine(data []byte) (int, error) {
+	n, statusLine, err := parseStatusLine(data)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	p.statusLine = *statusLine
+	return n, nil
+}
+
+// framing picks the body framing once all headers are known.
+func (p *Parser) framing(h headers.Headers) (message.Framing, error) {
+	code := p.statusLine.StatusCode
+	if p.method == "HEAD" || code < 200 || code == 204 || code == 304 {
+		return message.Framing{}, nil
+	}
+
+	if te, ok := h.Get("transfer-encoding"); ok {
+		// transfer-encoding overrides content-length; anything not ending in
+		// chunked is read until the connection closes
+		codings := strings.Split(te, ",")
+		last := strings.TrimSpace(codings[len(codings)-1])
+		if strings.EqualFold(last, "chunked") {
+			return message.Framing{Chunked: true}, nil
+		}
+		return message.Framing{UntilEOF: true}, nil
+	}
+
+	length, err := h.ContentLength()
+	if err != nil {
+		return message.Framing{}, ErrWrongBodyLength
+	}
+	if length < 0 {
+		return message.Framing{UntilEOF: true}, nil
+	}
+
+	return message.Framing{Length: length}, nil
+}
+
+func parseStatusLine(data []byte) (int, *StatusLine, error) {
+	idx := bytes.Index(data, []byte(lineSeparator))
+
+	if idx == -1 {
+		if len(data) > maxStatusLineLength {
+			return 0, nil, ErrStatusLineTooLong
+		}
+		return 0, nil, nil
+	}
+
+	version, rest, ok := strings.Cut(string(data[:idx]), " ")
+	if !ok {
+		return 0, nil, ErrWrongStatusLine
+	}
+
+	if version != "HTTP/1.1" && version != "HTTP/1.0" {
+		return 0, nil, ErrWrongVersionFormat
+	}
+
+	code, reason, _ := strings.Cut(rest, " ")
+	if len(code) != 3 {
+		return 0, nil, ErrWrongStatusCode
+	}
+
+	statusCode, err := strconv.Atoi(code)
+	if err != nil || statusCode < 100 {
+		return 0, nil, ErrWrongStatusCode
+	}
+
+	return idx + 2, &StatusLine{
+		HttpVersion: strings.TrimPrefix(version, "HTTP/"),
+		StatusCode:  StatusCode(statusCode),
+		Reason:      reason,
+	}, nil
+}
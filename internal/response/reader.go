@@ -0,0 +1,138 @@
+package response
+
+import (
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/message"
+	"io"
+)
+
+const bufferSize = 4096
+
+type Response struct {
+	StatusLine StatusLine
+	Headers    headers.Headers
+	Body       []byte
+	Trailers   headers.Headers
+}
+
+// Reader reads consecutive responses from one connection. Bytes that arrive
+// after a response are kept for the next one.
+type Reader struct {
+	buf    message.Buffer
+	parser *Parser
+}
+
+func NewReader(r io.Reader) *Reader {
+	rr := &Reader{
+		parser: NewParser(""),
+	}
+	rr.buf.Reset(r, make([]byte, bufferSize))
+	return rr
+}
+
+// Buffered returns the number of bytes read but not parsed yet.
+func (rr *Reader) Buffered() int {
+	return len(rr.buf.Bytes())
+}
+
+// Apply adds a parsed event to res.
+func (res *Response) Apply(ev Event) {
+	switch ev.Type {
+	case EventStatusLine:
+		res.StatusLine = ev.StatusLine
+	case EventHeader:
+		res.Headers.Add(ev.Key, ev.Value)
+	case EventBodyChunk:
+		res.Body = append(res.Body, ev.Data...)
+	case EventTrailer:
+		res.Trailers.Add(ev.Key, ev.Value)
+	}
+}
+
+// ReadResponse reads the whole response to a request with the given method.
+func (rr *Reader) ReadResponse(method string) (*Response, error) {
+	var res *Response
+
+	err := rr.Stream(method, func(ev Event) error {
+		if ev.Type == EventStatusLine {
+			res = &Response{
+				Headers:  headers.NewHeaders(),
+				Trailers: headers.NewHeaders(),
+			}
+		}
+		res.Apply(ev)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Stream reads one response to a request with the given method and passes
+// its events to fn as soon as they are parsed. Interim 1xx responses are
+// skipped. Event data is only valid during the call to fn.
+func (rr *Reader) Stream(method string, fn func(Event) error) error {
+	p := rr.parser
+	p.Reset(method)
+	interim := false
+
+	for {
+		n, err := p.Feed(rr.buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+			if ev.Type == EventStatusLine {
+				code := ev.StatusLine.StatusCode
+				interim = code >= 100 && code < 200 && code != 101
+			}
+
+			if interim {
+				continue
+			}
+
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+		rr.buf.Consume(n)
+
+		if p.Done() {
+			if !interim {
+				return nil
+			}
+			p.Reset(method)
+			interim = false
+			continue
+		}
+
+		if rr.buf.EOF() {
+			if err := p.Finish(); err != nil {
+				return err
+			}
+			for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+				if err := fn(ev); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if err := rr.buf.Fill(); err != nil {
+			return err
+		}
+	}
+}
+
+func ResponseFromReader(reader io.Reader) (*Response, error) {
+	return NewReader(reader).ReadResponse("")
+}
+
+// Closed reports whether the underlying reader hit EOF, so no further
+// responses can be read.
+func (rr *Reader) Closed() bool {
+	return rr.buf.EOF()
+}
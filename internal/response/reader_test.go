@@ -0,0 +1,175 @@
+package response
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type chunkReader struct {
+	data            string
+	numBytesPerRead int
+	pos             int
+}
+
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	if cr.pos >= len(cr.data) {
+		return 0, io.EOF
+	}
+	endIndex := min(cr.pos+cr.numBytesPerRead, len(cr.data))
+	n = copy(p, cr.data[cr.pos:endIndex])
+	cr.pos += n
+	return n, nil
+}
+
+func TestResponseFromReader(t *testing.T) {
+	tests := []struct {
+		data     string
+		status   StatusLine
+		headers  map[string]string
+		body     string
+		trailers map[string]string
+		notes    string
+	}{
+		{
+			data:    "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello",
+			status:  StatusLine{HttpVersion: "1.1", StatusCode: 200, Reason: "OK"},
+			headers: map[string]string{"content-type": "text/plain", "content-length": "5"},
+			body:    "hello",
+			notes:   "Content-Length body",
+		},
+		{
+			data: "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nTrailer: X-Content-Length\r\n\r\n" +
+				"6\r\nhello \r\n6\r\nworld!\r\n0\r\nX-Content-Length: 12\r\n\r\n",
+			status:   StatusLine{HttpVersion: "1.1", StatusCode: 200, Reason: "OK"},
+			headers:  map[string]string{"transfer-encoding": "chunked", "trailer": "X-Content-Length"},
+			body:     "hello world!",
+			trailers: map[string]string{"x-content-length": "12"},
+			notes:    "Chunked body with trailers",
+		},
+		{
+			data:    "HTTP/1.0 500 Internal Server Error\r\nContent-Type: text/html\r\n\r\n<p>until close</p>",
+			status:  StatusLine{HttpVersion: "1.0", StatusCode: 500, Reason: "Internal Server Error"},
+			headers: map[string]string{"content-type": "text/html"},
+			body:    "<p>until close</p>",
+			notes:   "Close-delimited body",
+		},
+		{
+			data:    "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 204 \r\nX-Request-Id: 42\r\n\r\n",
+			status:  StatusLine{HttpVersion: "1.1", StatusCode: 204},
+			headers: map[string]string{"x-request-id": "42"},
+			notes:   "Interim response and empty reason",
+		},
+	}
+
+	for _, tt := range tests {
+		res, err := ResponseFromReader(&chunkReader{data: tt.data, numBytesPerRead: 3})
+		require.NoError(t, err, tt.notes)
+		assert.Equal(t, tt.status, res.StatusLine, tt.notes+": status line")
+		for k, v := range tt.headers {
+			assert.Equal(t, v, res.Headers[k], tt.notes+": header "+k)
+		}
+		assert.Equal(t, tt.body, string(res.Body), tt.notes+": body")
+		for k, v := range tt.trailers {
+			assert.Equal(t, v, res.Trailers[k], tt.notes+": trailer "+k)
+		}
+	}
+}
+
+func TestResponseFromReaderErrors(t *testing.T) {
+	tests := []struct {
+		data  string
+		err   error
+		notes string
+	}{
+		{
+			data:  "HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nshort",
+			err:   ErrWrongBodyLength,
+			notes: "Body shorter than content length",
+		},
+		{
+			data:  "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhel",
+			err:   ErrIncompleteResponse,
+			notes: "Truncated chunked body",
+		},
+		{
+			data:  "HTTP/1.1 200 OK\r\nContent-Length: 5",
+			err:   ErrIncompleteResponse,
+			notes: "Truncated headers",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ResponseFromReader(&chunkReader{data: tt.data, numBytesPerRead: 3})
+		assert.Equal(t, tt.err, err, tt.notes)
+	}
+}
+
+func TestReaderConsecutiveResponses(t *testing.T) {
+	data := "HTTP/1.1 200 OK\r\nContent-Length: 3\r\n\r\none" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 1024\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nthree\r\n0\r\n\r\n"
+	rr := NewReader(strings.NewReader(data))
+
+	res, err := rr.ReadResponse("GET")
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(res.Body))
+
+	res, err = rr.ReadResponse("HEAD")
+	require.NoError(t, err)
+	assert.Empty(t, res.Body)
+
+	res, err = rr.ReadResponse("GET")
+	require.NoError(t, err)
+	assert.Equal(t, "three", string(res.Body))
+	assert.Equal(t, 0, rr.Buffered())
+}
+
+func TestParseStatusLine(t *testing.T) {
+	tests := []struct {
+		input string
+		n     int
+		line  StatusLine
+		err   error
+		notes string
+	}{
+		{
+			input: "HTTP/1.1 200 OK\r\n",
+			n:     17,
+			line:  StatusLine{HttpVersion: "1.1", StatusCode: 200, Reason: "OK"},
+			notes: "Status line",
+		},
+		{
+			input: "HTTP/1.1 204\r\n",
+			n:     14,
+			line:  StatusLine{HttpVersion: "1.1", StatusCode: 204},
+			notes: "Empty reason",
+		},
+		{
+			input: "HTTP/1.1 200 OK",
+			notes: "No new line",
+		},
+		{
+			input: "HTTP/2 200 OK\r\n",
+			err:   ErrWrongVersionFormat,
+			notes: "Wrong version",
+		},
+		{
+			input: "HTTP/1.1 2000 OK\r\n",
+			err:   ErrWrongStatusCode,
+			notes: "Wrong status code",
+		},
+	}
+
+	for _, tt := range tests {
+		n, line, err := parseStatusLine([]byte(tt.input))
+		assert.Equal(t, tt.err, err, tt.notes+": error")
+		assert.Equal(t, tt.n, n, tt.notes+": n")
+		if tt.n > 0 {
+			assert.Equal(t, tt.line, *line, tt.notes+": status line")
+		}
+	}
+}
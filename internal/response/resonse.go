@@ -10,11 +10,68 @@ import (
 type StatusCode int
 
 var (
-	OK           StatusCode = 200
-	BAD_REQUEST  StatusCode = 400
-	SERVER_ERROR StatusCode = 500
+	SWITCHING_PROTOCOLS    StatusCode = 101
+	OK                     StatusCode = 200
+	PARTIAL_CONTENT        StatusCode = 206
+	MOVED_PERMANENTLY      StatusCode = 301
+	NOT_MODIFIED           StatusCode = 304
+	BAD_REQUEST            StatusCode = 400
+	FORBIDDEN              StatusCode = 403
+	NOT_FOUND              StatusCode = 404
+	METHOD_NOT_ALLOWED     StatusCode = 405
+	NOT_ACCEPTABLE         StatusCode = 406
+	PRECONDITION_FAILED    StatusCode = 412
+	CONTENT_TOO_LARGE      StatusCode = 413
+	UNSUPPORTED_MEDIA_TYPE StatusCode = 415
+	RANGE_NOT_SATISFIABLE  StatusCode = 416
+	UPGRADE_REQUIRED       StatusCode = 426
+	SERVER_ERROR           StatusCode = 500
+	BAD_GATEWAY            StatusCode = 502
+	SERVICE_UNAVAILABLE    StatusCode = 503
+	GATEWAY_TIMEOUT        StatusCode = 504
 )
 
+var statusText = map[StatusCode]string{
+	100: "Continue",
+	101: "Switching Protocols",
+	200: "OK",
+	201: "Created",
+	202: "Accepted",
+	204: "No Content",
+	206: "Partial Content",
+	301: "Moved Permanently",
+	302: "Found",
+	303: "See Other",
+	304: "Not Modified",
+	307: "Temporary Redirect",
+	308: "Permanent Redirect",
+	400: "Bad Request",
+	401: "Unauthorized",
+	403: "Forbidden",
+	404: "Not Found",
+	405: "Method Not Allowed",
+	406: "Not Acceptable",
+	408: "Request Timeout",
+	409: "Conflict",
+	411: "Length Required",
+	412: "Precondition Failed",
+	413: "Content Too Large",
+	415: "Unsupported Media Type",
+	416: "Range Not Satisfiable",
+	426: "Upgrade Required",
+	429: "Too Many Requests",
+	500: "Internal Server Error",
+	501: "Not Implemented",
+	502: "Bad Gateway",
+	503: "Service Unavailable",
+	504: "Gateway Timeout",
+}
+
+// StatusText returns the reason phrase for code, or "" when it is unknown.
+func StatusText(code StatusCode) string {
+	return statusText[code]
+}
+
 type writeState int
 
 var (
@@ -53,7 +110,7 @@ func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
 	case SERVER_ERROR:
 		_, err = w.w.Write([]byte("HTTP/1.1 500 Internal Server Error\r\n"))
 	default:
-		_, err = w.w.Write([]byte(fmt.Sprintf("HTTP/1.1 %v\r\n", statusCode)))
+		_, err = w.w.Write([]byte(fmt.Sprintf("HTTP/1.1 %v %s\r\n", statusCode, StatusText(statusCode))))
 	}
 
 	if err == nil {
@@ -68,10 +125,12 @@ func (w *Writer) WriteHeaders(h headers.Headers) error {
 		return ErrWrongWriteOrder
 	}
 	format := "%s: %v\r\n"
-	for key, value := range h {
-		_, err := w.w.Write([]byte(fmt.Sprintf(format, key, value)))
-		if err != nil {
-			return err
+	for key := range h {
+		for _, value := range h.Lines(key) {
+			_, err := w.w.Write([]byte(fmt.Sprintf(format, key, value)))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -88,10 +147,12 @@ func (w *Writer) WriteTrailers(h headers.Headers) error {
 	}
 
 	format := "%s: %v\r\n"
-	for key, value := range h {
-		_, err := w.w.Write([]byte(fmt.Sprintf(format, key, value)))
-		if err != nil {
-			return err
+	for key := range h {
+		for _, value := range h.Lines(key) {
+			_, err := w.w.Write([]byte(fmt.Sprintf(format, key, value)))
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -100,7 +161,7 @@ func (w *Writer) WriteTrailers(h headers.Headers) error {
 }
 
 func (w *Writer) WriteBody(data []byte) (int, error) {
-	if w.state != headersState {
+	if w.state != headersState && w.state != bodyState {
 		return 0, ErrWrongWriteOrder
 	}
 
@@ -109,6 +170,22 @@ func (w *Writer) WriteBody(data []byte) (int, error) {
 	return w.w.Write(data)
 }
 
+// WriteBodyFrom copies the body from r. When the destination is an
+// io.ReaderFrom it gets r as is, so connections can send files with
+// sendfile; io.Copy would prefer *os.File's WriteTo and hide the file.
+func (w *Writer) WriteBodyFrom(r io.Reader) (int64, error) {
+	if w.state != headersState && w.state != bodyState {
+		return 0, ErrWrongWriteOrder
+	}
+
+	w.state = bodyState
+
+	if rf, ok := w.w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+	return io.Copy(w.w, r)
+}
+
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	if w.state != headersState {
 		return 0, ErrWrongWriteOrder
@@ -126,3 +203,42 @@ func (w *Writer) WriteChunkedBodyDone(useTrailers bool) (int, error) {
 	}
 	return w.w.Write([]byte("0\r\n\r\n"))
 }
+
+// Flusher is implemented by writers that buffer output, such as compressing
+// writers.
+type Flusher interface {
+	Flush() error
+}
+
+// Flush pushes out anything buffered by the underlying writer. Writes to a
+// plain connection aren't buffered, so it's a no-op there.
+func (w *Writer) Flush() error {
+	if f, ok := w.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// WriteError writes a complete plain-text response for err with the given
+// status.
+func WriteError(w io.Writer, statusCode StatusCode, err error) error {
+	return WriteErrorHeaders(w, statusCode, nil, err)
+}
+
+// WriteErrorHeaders is WriteError with extra fields, like Allow for a 405.
+func WriteErrorHeaders(w io.Writer, statusCode StatusCode, extra map[string]string, err error) error {
+	rw := NewWriter(w)
+	if writeErr := rw.WriteStatusLine(statusCode); writeErr != nil {
+		return writeErr
+	}
+
+	body := []byte(fmt.Sprintf("%d %s: %v\n", statusCode, StatusText(statusCode), err))
+	h := headers.NewHeaders()
+	h.SetDefault(len(body), extra)
+	if writeErr := rw.WriteHeaders(h); writeErr != nil {
+		return writeErr
+	}
+
+	_, writeErr := rw.WriteBody(body)
+	return writeErr
+}
@@ -0,0 +1,104 @@
+package response
+
+import (
+	"httpfromtcp/internal/headers"
+	"io"
+	"strings"
+	"testing"
+)
+
+func benchHeaders() headers.Headers {
+	h := headers.NewHeaders()
+	h.SetDefault(1024, map[string]string{
+		"content-type": "text/html",
+		"x-request-id": "a1b2c3d4",
+	})
+	return h
+}
+
+func BenchmarkWriteStatusLine(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard)
+		if err := w.WriteStatusLine(OK); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteHeaders(b *testing.B) {
+	h := benchHeaders()
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard)
+		w.state = statusLineState
+		if err := w.WriteHeaders(h); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteBody(b *testing.B) {
+	body := []byte(strings.Repeat("x", 1024))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard)
+		w.state = headersState
+		if _, err := w.WriteBody(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteChunkedBody(b *testing.B) {
+	chunk := []byte(strings.Repeat("x", 1024))
+	trailers := headers.NewHeaders()
+	trailers.Set(map[string]string{
+		"x-content-length": "8192",
+	})
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(chunk) * 8))
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard)
+		w.state = headersState
+		for j := 0; j < 8; j++ {
+			if _, err := w.WriteChunkedBody(chunk); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if _, err := w.WriteChunkedBodyDone(true); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.WriteTrailers(trailers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteResponse(b *testing.B) {
+	h := benchHeaders()
+	body := []byte(strings.Repeat("x", 1024))
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(io.Discard)
+		if err := w.WriteStatusLine(OK); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.WriteHeaders(h); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := w.WriteBody(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"sync/atomic"
+	"time"
+)
+
+// responses with a head larger than this are passed on untouched
+const maxHeadSize = 64 << 10
+
+type cachedDate struct {
+	unix  int64
+	value string
+}
+
+var dateCache atomic.Pointer[cachedDate]
+
+// httpDate returns the current IMF-fixdate, formatted at most once a
+// second.
+func httpDate() string {
+	now := time.Now()
+	if cached := dateCache.Load(); cached != nil && cached.unix == now.Unix() {
+		return cached.value
+	}
+
+	cached := &cachedDate{unix: now.Unix(), value: headers.FormatTime(now)}
+	dateCache.Store(cached)
+	return cached.value
+}
+
+// addFields adds Date and, when name is set, Server to a complete response
+// head unless the handler already wrote them.
+func addFields(head []byte, name string) []byte {
+	if !bytes.HasPrefix(head, []byte("HTTP/")) {
+		return head
+	}
+
+	hasDate, hasServer := false, false
+	lines := bytes.Split(head[:len(head)-4], []byte("\r\n"))
+	for _, line := range lines[1:] {
+		key, _, _ := bytes.Cut(line, []byte(":"))
+		key = bytes.TrimSpace(key)
+		hasDate = hasDate || bytes.EqualFold(key, []byte("date"))
+		hasServer = hasServer || bytes.EqualFold(key, []byte("server"))
+	}
+
+	if hasDate && (hasServer || name == "") {
+		return head
+	}
+
+	out := make([]byte, 0, len(head)+64)
+	out = append(out, head[:len(head)-2]...)
+	if !hasDate {
+		out = append(out, "date: "+httpDate()+"\r\n"...)
+	}
+	if !hasServer && name != "" {
+		out = append(out, "server: "+name+"\r\n"...)
+	}
+	return append(out, "\r\n"...)
+}
+
+// isInterim reports whether head belongs to a 1xx response another head
+// follows. A 101 hands the connection to another protocol instead.
+func isInterim(head []byte) bool {
+	_, rest, ok := bytes.Cut(head, []byte(" "))
+	return ok && len(rest) >= 3 && rest[0] == '1' && !bytes.HasPrefix(rest, []byte("101"))
+}
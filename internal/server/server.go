@@ -1,41 +1,150 @@
 package server
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"httpfromtcp/internal/headers"
 	"httpfromtcp/internal/request"
 	"httpfromtcp/internal/response"
 	"io"
 	"net"
+	"os"
 	"strconv"
 	"sync/atomic"
 	"time"
 )
 
-func writeError(w io.Writer, err error) error {
-	rw := response.NewWriter(w)
-	writeErr := rw.WriteStatusLine(response.SERVER_ERROR)
+// errorStatus picks the status for a request that couldn't be read.
+func errorStatus(err error) response.StatusCode {
+	if errors.Is(err, request.ErrBodyTooLarge) {
+		return response.CONTENT_TOO_LARGE
+	}
+	return response.SERVER_ERROR
+}
+
+type Handler func(w io.Writer, req *request.Request)
+
+var (
+	ErrHijacked = errors.New("connection has been hijacked")
+	// ErrNotHijackable is for handlers that need the connection but were
+	// given a writer that isn't a Hijacker.
+	ErrNotHijackable = errors.New("connection can't be taken over")
+)
 
-	if writeErr != nil {
-		return writeErr
+// Hijacker is implemented by the writer handlers get. After Hijack the
+// handler owns the connection: the server neither writes to it nor closes
+// it, and deadlines are cleared. The returned bytes were read from the
+// connection but not parsed as part of the request.
+type Hijacker interface {
+	Hijack() (net.Conn, []byte, error)
+}
+
+// conn is the writer passed to handlers. It holds back each response head
+// until it is complete to add the Date and Server fields.
+type conn struct {
+	netConn  net.Conn
+	reader   *request.Reader
+	name     string
+	head     []byte
+	headDone bool
+	hijacked bool
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	if c.hijacked {
+		return 0, ErrHijacked
+	}
+	if c.headDone {
+		return c.netConn.Write(p)
 	}
 
-	errData := []byte(err.Error())
-	h := headers.NewHeaders()
-	h.SetDefault(len(errData), nil)
-	writeErr = rw.WriteHeaders(h)
-	if writeErr != nil {
-		return writeErr
+	c.head = append(c.head, p...)
+	if err := c.writeHeads(); err != nil {
+		return 0, err
 	}
-	_, writeErr = rw.WriteBody(errData)
-	return writeErr
+	return len(p), nil
+}
 
+// writeHeads sends the complete heads buffered so far; interim 1xx heads
+// are followed by another one.
+func (c *conn) writeHeads() error {
+	for !c.headDone {
+		end := bytes.Index(c.head, []byte("\r\n\r\n"))
+		if end < 0 {
+			if len(c.head) > maxHeadSize {
+				return c.flushHead()
+			}
+			return nil
+		}
+
+		head := c.head[:end+4]
+		if _, err := c.netConn.Write(addFields(head, c.name)); err != nil {
+			return err
+		}
+		c.head = c.head[end+4:]
+
+		if !isInterim(head) {
+			return c.flushHead()
+		}
+	}
+	return nil
 }
 
-type Handler func(w io.Writer, req *request.Request)
+// flushHead stops looking for heads and sends what is buffered as is.
+func (c *conn) flushHead() error {
+	c.headDone = true
+	pending := c.head
+	c.head = nil
+
+	if len(pending) == 0 {
+		return nil
+	}
+	_, err := c.netConn.Write(pending)
+	return err
+}
+
+// ReadFrom hands the copy to the connection. *net.TCPConn sends an *os.File,
+// or an *io.LimitedReader around one, with sendfile on Linux instead of
+// copying it through user space.
+func (c *conn) ReadFrom(r io.Reader) (int64, error) {
+	if c.hijacked {
+		return 0, ErrHijacked
+	}
+	if err := c.flushHead(); err != nil {
+		return 0, err
+	}
+
+	if tcp, ok := c.netConn.(*net.TCPConn); ok && isFile(r) {
+		return tcp.ReadFrom(r)
+	}
+	return io.Copy(c.netConn, r)
+}
+
+func isFile(r io.Reader) bool {
+	if lr, ok := r.(*io.LimitedReader); ok {
+		r = lr.R
+	}
+	_, ok := r.(*os.File)
+	return ok
+}
+
+func (c *conn) Hijack() (net.Conn, []byte, error) {
+	if c.hijacked {
+		return nil, nil, ErrHijacked
+	}
+	if err := c.flushHead(); err != nil {
+		return nil, nil, err
+	}
+	c.hijacked = true
+
+	c.netConn.SetDeadline(time.Time{})
+	buffered := append([]byte(nil), c.reader.Buffered()...)
+	return c.netConn, buffered, nil
+}
 
 type Server struct {
 	isOpen   atomic.Bool
+	name     atomic.Value
 	listener net.Listener
 	Handler  Handler
 }
@@ -59,6 +168,16 @@ func Serv(port int, handler Handler) (*Server, error) {
 	return server, nil
 }
 
+// SetName sets the Server field added to responses that lack one; empty
+// leaves it out.
+func (s *Server) SetName(name string) {
+	s.name.Store(name)
+}
+
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
 func (s *Server) Close() error {
 	fmt.Println("Server closed")
 	s.isOpen.Store(false)
@@ -79,24 +198,43 @@ func (s *Server) listen() {
 	}
 }
 
-func (s *Server) handle(conn net.Conn) {
+func (s *Server) handle(netConn net.Conn) {
+	reader := request.NewReader(netConn)
+	name, _ := s.name.Load().(string)
+	c := &conn{
+		netConn: netConn,
+		reader:  reader,
+		name:    name,
+	}
+
 	defer func() {
+		reader.Release()
+		if c.hijacked {
+			return
+		}
 		fmt.Println("Connection closed")
-		conn.Close()
+		netConn.Close()
 	}()
 
 	timeoutDuration := 1 * time.Second
-	conn.SetReadDeadline(time.Now().Add(timeoutDuration))
+	netConn.SetReadDeadline(time.Now().Add(timeoutDuration))
 
-	r, err := request.RequestFromReader(conn)
+	r, err := reader.ReadRequest()
 
 	if err != nil {
-		err = writeError(conn, err)
+		err = response.WriteError(c, errorStatus(err), err)
+		if err == nil {
+			err = c.flushHead()
+		}
 		if err != nil {
 			fmt.Printf("Request error: %v\n", err)
 		}
 		return
 	}
 
-	s.Handler(conn, r)
+	r.RemoteAddr = netConn.RemoteAddr().String()
+	s.Handler(c, r)
+	if !c.hijacked {
+		c.flushHead()
+	}
 }
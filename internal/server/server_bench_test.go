@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"httpfromtcp/internal/fileserver"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// silenceStdout hides the server's per-connection logging.
+func silenceStdout(b *testing.B) {
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	os.Stdout = devNull
+	b.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+func BenchmarkServ(b *testing.B) {
+	silenceStdout(b)
+
+	body := []byte(strings.Repeat("x", 1024))
+	handler := func(w io.Writer, r *request.Request) {
+		rw := response.NewWriter(w)
+		rw.WriteStatusLine(response.OK)
+		h := headers.NewHeaders()
+		h.SetDefault(len(body), nil)
+		rw.WriteHeaders(h)
+		rw.WriteBody(body)
+	}
+
+	s, err := Serv(0, handler)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
+
+	addr := s.listener.Addr().String()
+	req := []byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := conn.Write(req); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := io.Copy(io.Discard, conn); err != nil {
+			b.Fatal(err)
+		}
+		conn.Close()
+	}
+}
+
+// BenchmarkStaticFile serves a file through the file server, once with the
+// connection's ReadFrom (sendfile) and once forced through a user space copy.
+func BenchmarkStaticFile(b *testing.B) {
+	silenceStdout(b)
+
+	const size = 8 << 20
+
+	dir := b.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "asset.bin"), bytes.Repeat([]byte("x"), size), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	assets, err := fileserver.NewFileServer(dir)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer assets.Close()
+
+	modes := []struct {
+		name string
+		wrap func(io.Writer) io.Writer
+	}{
+		{"sendfile", func(w io.Writer) io.Writer { return w }},
+		// hiding ReadFrom leaves io.Copy with its 32KB buffer
+		{"copy", func(w io.Writer) io.Writer { return struct{ io.Writer }{w} }},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			s, err := Serv(0, func(w io.Writer, r *request.Request) {
+				assets.ServeFile(mode.wrap(w), r, "asset.bin")
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer s.Close()
+
+			addr := s.Addr().String()
+			req := []byte("GET /asset.bin HTTP/1.1\r\nHost: localhost\r\n\r\n")
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				conn, err := net.Dial("tcp", addr)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if _, err := conn.Write(req); err != nil {
+					b.Fatal(err)
+				}
+
+				n, err := io.Copy(io.Discard, conn)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if n < size {
+					b.Fatalf("short response: %d bytes", n)
+				}
+				conn.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHijack(t *testing.T) {
+	type result struct {
+		hijackable bool
+		err        error
+		buffered   []byte
+		writeErr   error
+		againErr   error
+	}
+	results := make(chan result, 1)
+
+	handler := func(w io.Writer, r *request.Request) {
+		hijacker, ok := w.(Hijacker)
+		if !ok {
+			results <- result{}
+			return
+		}
+
+		conn, buffered, err := hijacker.Hijack()
+		if err != nil {
+			results <- result{hijackable: true, err: err}
+			return
+		}
+
+		_, writeErr := w.Write([]byte("nope"))
+		_, _, againErr := hijacker.Hijack()
+		results <- result{true, nil, buffered, writeErr, againErr}
+
+		// keep using the connection after the handler has returned
+		go func() {
+			defer conn.Close()
+			conn.Write([]byte("hijacked:"))
+			io.Copy(conn, conn)
+		}()
+	}
+
+	s, err := Serv(0, handler)
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /upgrade HTTP/1.1\r\nHost: localhost\r\n\r\nextra"))
+	require.NoError(t, err)
+
+	res := <-results
+	require.True(t, res.hijackable)
+	require.NoError(t, res.err)
+	assert.Equal(t, "extra", string(res.buffered))
+	assert.ErrorIs(t, res.writeErr, ErrHijacked)
+	assert.ErrorIs(t, res.againErr, ErrHijacked)
+
+	// outlive the server's read deadline
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	buf := make([]byte, len("hijacked:ping"))
+	_, err = io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hijacked:ping", string(buf))
+}
+
+func TestReadFromFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+	path := filepath.Join(t.TempDir(), "body.bin")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	type result struct {
+		n   int64
+		err error
+	}
+	results := make(chan result, 1)
+
+	handler := func(w io.Writer, r *request.Request) {
+		rw := response.NewWriter(w)
+		rw.WriteStatusLine(response.OK)
+		h := headers.NewHeaders()
+		h.SetDefault(len(content)-10, nil)
+		rw.WriteHeaders(h)
+
+		if _, err := f.Seek(10, io.SeekStart); err != nil {
+			results <- result{0, err}
+			return
+		}
+		n, err := rw.WriteBodyFrom(io.LimitReader(f, int64(len(content)-10)))
+		results <- result{n, err}
+	}
+
+	s, err := Serv(0, handler)
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /body.bin HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	res, err := response.ResponseFromReader(conn)
+	require.NoError(t, err)
+	assert.Equal(t, content[10:], res.Body)
+
+	written := <-results
+	require.NoError(t, written.err)
+	assert.Equal(t, int64(len(content)-10), written.n)
+}
+
+func TestDateAndServerFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		serverName string
+		date       string
+		server     string
+	}{
+		{
+			name:   "Adds date",
+			output: "HTTP/1.1 200 OK\r\ncontent-length: 2\r\n\r\nok",
+		},
+		{
+			name:       "Adds server",
+			output:     "HTTP/1.1 200 OK\r\ncontent-length: 2\r\n\r\nok",
+			serverName: "httpfromtcp",
+			server:     "httpfromtcp",
+		},
+		{
+			name:       "Keeps handler values",
+			output:     "HTTP/1.1 200 OK\r\nDate: Sun, 06 Nov 1994 08:49:37 GMT\r\nServer: custom\r\ncontent-length: 2\r\n\r\nok",
+			serverName: "httpfromtcp",
+			date:       "Sun, 06 Nov 1994 08:49:37 GMT",
+			server:     "custom",
+		},
+		{
+			name:   "After interim response",
+			output: "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\ncontent-length: 2\r\n\r\nok",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(w io.Writer, r *request.Request) {
+				// the head arrives in pieces, as response.Writer sends it
+				for _, part := range bytes.SplitAfter([]byte(tc.output), []byte("\n")) {
+					w.Write(part)
+				}
+			}
+
+			s, err := Serv(0, handler)
+			require.NoError(t, err)
+			defer s.Close()
+			s.SetName(tc.serverName)
+
+			conn, err := net.Dial("tcp", s.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+			require.NoError(t, err)
+
+			res, err := response.NewReader(conn).ReadResponse("GET")
+			require.NoError(t, err)
+			assert.Equal(t, response.OK, res.StatusLine.StatusCode)
+			assert.Equal(t, "ok", string(res.Body))
+
+			if tc.date != "" {
+				assert.Equal(t, tc.date, res.Headers["date"])
+			} else {
+				date, ok := res.Headers.Time("date")
+				require.True(t, ok)
+				assert.WithinDuration(t, time.Now(), date, 2*time.Second)
+			}
+
+			server, ok := res.Headers.Get("server")
+			assert.Equal(t, tc.server != "", ok)
+			assert.Equal(t, tc.server, server)
+		})
+	}
+}
+
+func TestHTTPDateCached(t *testing.T) {
+	date := httpDate()
+	cached := dateCache.Load()
+	require.Equal(t, date, cached.value)
+
+	_, err := headers.ParseTime(date)
+	assert.NoError(t, err)
+
+	// within the same second the formatted value is reused
+	httpDate()
+	if dateCache.Load().unix == cached.unix {
+		assert.Same(t, cached, dateCache.Load())
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	s, err := Serv(0, func(w io.Writer, r *request.Request) {
+		t.Error("handler called for an oversized body")
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprintf(conn, "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: %d\r\n\r\n", request.DefaultMaxBodySize+1)
+	require.NoError(t, err)
+
+	res, err := response.ResponseFromReader(conn)
+	require.NoError(t, err)
+	assert.Equal(t, response.CONTENT_TOO_LARGE, res.StatusLine.StatusCode)
+}
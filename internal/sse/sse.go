@@ -0,0 +1,236 @@
+package sse
+
+import (
+	"errors"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeartbeat is the Heartbeat of new writers.
+const DefaultHeartbeat = 15 * time.Second
+
+var (
+	ErrWrongField = errors.New("event field contains a line break")
+	ErrClosed     = errors.New("event stream closed")
+)
+
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Retry tells the client how long to wait before reconnecting; zero
+	// leaves it unset.
+	Retry time.Duration
+}
+
+// Writer streams server-sent events over a chunked response. Once a write
+// fails the client is considered gone: Done is closed and every later call
+// returns the error.
+type Writer struct {
+	rw *response.Writer
+
+	// LastEventID is the id the client last saw before reconnecting.
+	LastEventID string
+	// Heartbeat is how often a heartbeat comment is sent; zero turns
+	// heartbeats off. It is read by the first Send, Comment or Done call.
+	Heartbeat time.Duration
+
+	mu        sync.Mutex
+	err       error
+	done      chan struct{}
+	hbStarted bool
+	stopHb    chan struct{}
+}
+
+// NewWriter writes the response head for an event stream to r.
+func NewWriter(w io.Writer, r *request.Request) (*Writer, error) {
+	rw := response.NewWriter(w)
+	if err := rw.WriteStatusLine(response.OK); err != nil {
+		return nil, err
+	}
+
+	h := headers.NewHeaders()
+	h["content-type"] = "text/event-stream"
+	h["cache-control"] = "no-cache"
+	h["transfer-encoding"] = "chunked"
+	h["connection"] = "close"
+	if err := rw.WriteHeaders(h); err != nil {
+		return nil, err
+	}
+
+	lastID, _ := r.Headers.Get("last-event-id")
+
+	s := &Writer{
+		rw:          rw,
+		LastEventID: strings.TrimSpace(lastID),
+		Heartbeat:   DefaultHeartbeat,
+		done:        make(chan struct{}),
+	}
+	return s, nil
+}
+
+// Done is closed once the stream ended, either because a write failed, a
+// heartbeat included, as the client went away, or because Close was
+// called.
+func (s *Writer) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.autoHeartbeat()
+	return s.done
+}
+
+func (s *Writer) Send(ev Event) error {
+	data, err := formatEvent(ev)
+	if err != nil {
+		return err
+	}
+	return s.write(data)
+}
+
+// Comment sends a comment line, which clients ignore.
+func (s *Writer) Comment(text string) error {
+	if strings.ContainsAny(text, "\r\n") {
+		return ErrWrongField
+	}
+	return s.write([]byte(": " + text + "\n\n"))
+}
+
+// StartHeartbeat sends a comment every interval so idle proxies keep the
+// connection open and a vanished client is noticed. It does nothing while
+// a heartbeat is running.
+func (s *Writer) StartHeartbeat(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.startHeartbeat(interval)
+}
+
+// autoHeartbeat starts the heartbeat every Heartbeat unless one was started
+// or stopped before. It must be called with mu held.
+func (s *Writer) autoHeartbeat() {
+	if !s.hbStarted && s.Heartbeat > 0 {
+		s.startHeartbeat(s.Heartbeat)
+	}
+	s.hbStarted = true
+}
+
+// startHeartbeat must be called with mu held.
+func (s *Writer) startHeartbeat(interval time.Duration) {
+	s.hbStarted = true
+	if s.stopHb != nil || s.err != nil {
+		return
+	}
+	stop := make(chan struct{})
+	s.stopHb = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if s.Comment("heartbeat") != nil {
+					return
+				}
+			case <-stop:
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Writer) StopHeartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hbStarted = true
+	if s.stopHb != nil {
+		close(s.stopHb)
+		s.stopHb = nil
+	}
+}
+
+// Close stops the heartbeat and ends the chunked body.
+func (s *Writer) Close() error {
+	s.StopHeartbeat()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		if errors.Is(s.err, ErrClosed) {
+			return nil
+		}
+		return s.err
+	}
+
+	_, err := s.rw.WriteChunkedBodyDone(false)
+	s.fail(ErrClosed)
+	return err
+}
+
+func (s *Writer) write(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.autoHeartbeat()
+	if s.err != nil {
+		return s.err
+	}
+
+	if _, err := s.rw.WriteChunkedBody(data); err != nil {
+		s.fail(err)
+		return err
+	}
+	if err := s.rw.Flush(); err != nil {
+		s.fail(err)
+		return err
+	}
+	return nil
+}
+
+// fail must be called with mu held.
+func (s *Writer) fail(err error) {
+	s.err = err
+	close(s.done)
+}
+
+func formatEvent(ev Event) ([]byte, error) {
+	if strings.ContainsAny(ev.Event, "\r\n") || strings.ContainsAny(ev.ID, "\r\n\x00") {
+		return nil, ErrWrongField
+	}
+
+	var b strings.Builder
+
+	if ev.ID != "" {
+		b.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		b.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	// every line of the payload becomes its own data field
+	if ev.Data != "" {
+		data := strings.ReplaceAll(ev.Data, "\r\n", "\n")
+		data = strings.ReplaceAll(data, "\r", "\n")
+		for _, line := range strings.Split(data, "\n") {
+			b.WriteString("data: " + line + "\n")
+		}
+	}
+
+	b.WriteString("\n")
+	return []byte(b.String()), nil
+}
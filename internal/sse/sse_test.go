@@ -0,0 +1,168 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    Event
+		expected string
+		err      error
+	}{
+		{
+			name:     "Data only",
+			event:    Event{Data: "hello"},
+			expected: "data: hello\n\n",
+		},
+		{
+			name:     "All fields",
+			event:    Event{ID: "7", Event: "update", Data: "a\nb\r\nc\rd", Retry: 1500 * time.Millisecond},
+			expected: "id: 7\nevent: update\nretry: 1500\ndata: a\ndata: b\ndata: c\ndata: d\n\n",
+		},
+		{
+			name:     "Retry only",
+			event:    Event{Retry: 3 * time.Second},
+			expected: "retry: 3000\n\n",
+		},
+		{
+			name:  "Line break in event",
+			event: Event{Event: "a\nb", Data: "x"},
+			err:   ErrWrongField,
+		},
+		{
+			name:  "NUL in id",
+			event: Event{ID: "a\x00", Data: "x"},
+			err:   ErrWrongField,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := formatEvent(tc.event)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	r := &request.Request{Headers: headers.NewHeaders()}
+	r.Headers["last-event-id"] = " 41 "
+
+	var buf bytes.Buffer
+	s, err := NewWriter(&buf, r)
+	require.NoError(t, err)
+	s.Heartbeat = 0
+	assert.Equal(t, "41", s.LastEventID)
+
+	require.NoError(t, s.Send(Event{ID: "42", Data: "tick"}))
+	require.NoError(t, s.Comment("still here"))
+	require.NoError(t, s.Close())
+
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("Done not closed after Close")
+	}
+	assert.ErrorIs(t, s.Send(Event{Data: "late"}), ErrClosed)
+	assert.NoError(t, s.Close())
+
+	res, err := response.ResponseFromReader(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, response.OK, res.StatusLine.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Headers["content-type"])
+	assert.Equal(t, "no-cache", res.Headers["cache-control"])
+	assert.Equal(t, "id: 42\ndata: tick\n\n: still here\n\n", string(res.Body))
+}
+
+func TestWriterStopsWhenClientGoesAway(t *testing.T) {
+	gone := make(chan error, 1)
+
+	s, err := server.Serv(0, func(w io.Writer, r *request.Request) {
+		events, err := NewWriter(w, r)
+		if err != nil {
+			gone <- err
+			return
+		}
+		events.Heartbeat = 10 * time.Millisecond
+
+		select {
+		case <-events.Done():
+			gone <- events.Send(Event{Data: "anyone?"})
+		case <-time.After(5 * time.Second):
+			gone <- nil
+		}
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("GET /events HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", line)
+	conn.Close()
+
+	assert.Error(t, <-gone)
+}
+
+func TestWriterHeartbeat(t *testing.T) {
+	r := &request.Request{Headers: headers.NewHeaders()}
+
+	s, err := NewWriter(io.Discard, r)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultHeartbeat, s.Heartbeat)
+	assert.Nil(t, s.stopHb, "not started before the stream is used")
+	s.Done()
+	assert.NotNil(t, s.stopHb, "heartbeat runs by default")
+	require.NoError(t, s.Close())
+
+	s, err = NewWriter(io.Discard, r)
+	require.NoError(t, err)
+	s.Heartbeat = 0
+	require.NoError(t, s.Send(Event{Data: "x"}))
+	assert.Nil(t, s.stopHb)
+	require.NoError(t, s.Close())
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		s, err := NewWriter(server, r)
+		if err == nil {
+			s.Heartbeat = 10 * time.Millisecond
+			<-s.Done()
+		}
+		server.Close()
+	}()
+
+	br := bufio.NewReader(client)
+	for {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		if line == ": heartbeat\n" {
+			break
+		}
+	}
+}
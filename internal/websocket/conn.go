@@ -0,0 +1,423 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxMessageSize = 16 << 20
+	maxControlPayload     = 125
+	closeTimeout          = 5 * time.Second
+)
+
+type Opcode byte
+
+var (
+	ContinuationFrame Opcode = 0x0
+	TextMessage       Opcode = 0x1
+	BinaryMessage     Opcode = 0x2
+	CloseMessage      Opcode = 0x8
+	PingMessage       Opcode = 0x9
+	PongMessage       Opcode = 0xA
+)
+
+func (op Opcode) isControl() bool {
+	return op&0x8 != 0
+}
+
+type CloseCode int
+
+var (
+	CloseNormal          CloseCode = 1000
+	CloseGoingAway       CloseCode = 1001
+	CloseProtocolError   CloseCode = 1002
+	CloseUnsupportedData CloseCode = 1003
+	CloseNoStatus        CloseCode = 1005
+	CloseAbnormal        CloseCode = 1006
+	CloseInvalidPayload  CloseCode = 1007
+	ClosePolicyViolation CloseCode = 1008
+	CloseMessageTooBig   CloseCode = 1009
+	CloseInternalError   CloseCode = 1011
+)
+
+var (
+	ErrProtocol        = errors.New("websocket protocol violation")
+	ErrMessageTooLarge = errors.New("websocket message too large")
+	ErrInvalidUTF8     = errors.New("websocket text is not valid utf-8")
+	ErrControlTooLarge = errors.New("websocket control frame payload too large")
+	ErrClosed          = errors.New("websocket connection closed")
+)
+
+// CloseError is returned by ReadMessage once the peer closed the connection.
+type CloseError struct {
+	Code   CloseCode
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+type Frame struct {
+	Fin     bool
+	Opcode  Opcode
+	Payload []byte
+}
+
+// Conn is a websocket connection. Reads must come from one goroutine;
+// writes may come from several.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool
+
+	// MaxMessageSize limits the assembled size of a data message and of a
+	// single frame.
+	MaxMessageSize int64
+	Subprotocol    string
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	// receiving holds the opcode of the fragmented message being read
+	receiving Opcode
+	message   []byte
+}
+
+func newConn(conn net.Conn, buffered []byte, client bool) *Conn {
+	var r io.Reader = conn
+	if len(buffered) > 0 {
+		r = io.MultiReader(bytes.NewReader(buffered), conn)
+	}
+
+	return &Conn{
+		conn:           conn,
+		reader:         bufio.NewReader(r),
+		client:         client,
+		MaxMessageSize: defaultMaxMessageSize,
+		receiving:      ContinuationFrame,
+	}
+}
+
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// ReadFrame reads a single frame and unmasks its payload. It only checks the
+// frame itself; ReadMessage enforces the rules between frames.
+func (c *Conn) ReadFrame() (Frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.reader, head[:]); err != nil {
+		return Frame{}, err
+	}
+
+	f := Frame{
+		Fin:    head[0]&0x80 != 0,
+		Opcode: Opcode(head[0] & 0x0F),
+	}
+
+	// no extensions are negotiated, so the reserved bits must be clear
+	if head[0]&0x70 != 0 {
+		return Frame{}, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+
+	switch f.Opcode {
+	case ContinuationFrame, TextMessage, BinaryMessage, CloseMessage, PingMessage, PongMessage:
+	default:
+		return Frame{}, fmt.Errorf("%w: unknown opcode %d", ErrProtocol, f.Opcode)
+	}
+
+	masked := head[1]&0x80 != 0
+	// clients mask every frame, servers never do
+	if masked == c.client {
+		return Frame{}, fmt.Errorf("%w: wrong masking", ErrProtocol)
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return Frame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return Frame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+		if length>>63 != 0 {
+			return Frame{}, fmt.Errorf("%w: payload length overflows", ErrProtocol)
+		}
+	}
+
+	if f.Opcode.isControl() {
+		if length > maxControlPayload {
+			return Frame{}, ErrControlTooLarge
+		}
+		if !f.Fin {
+			return Frame{}, fmt.Errorf("%w: fragmented control frame", ErrProtocol)
+		}
+	}
+
+	if c.MaxMessageSize > 0 && length > uint64(c.MaxMessageSize) {
+		return Frame{}, ErrMessageTooLarge
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, key[:]); err != nil {
+			return Frame{}, err
+		}
+	}
+
+	f.Payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, f.Payload); err != nil {
+		return Frame{}, err
+	}
+
+	if masked {
+		mask(key, f.Payload)
+	}
+
+	return f, nil
+}
+
+// WriteFrame writes f, masking the payload on client connections.
+// Fragmented messages are sent as a first frame with Fin unset followed by
+// continuation frames, the last of which sets Fin.
+func (c *Conn) WriteFrame(f Frame) error {
+	if f.Opcode.isControl() && len(f.Payload) > maxControlPayload {
+		return ErrControlTooLarge
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	return c.writeFrame(f)
+}
+
+func (c *Conn) writeFrame(f Frame) error {
+	buf := make([]byte, 0, 14+len(f.Payload))
+
+	b0 := byte(f.Opcode)
+	if f.Fin {
+		b0 |= 0x80
+	}
+	buf = append(buf, b0)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+
+	length := len(f.Payload)
+	switch {
+	case length <= 125:
+		buf = append(buf, maskBit|byte(length))
+	case length <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(length))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(length))
+	}
+
+	if c.client {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, f.Payload...)
+		mask(key, buf[start:])
+	} else {
+		buf = append(buf, f.Payload...)
+	}
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func mask(key [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= key[i%4]
+	}
+}
+
+// ReadMessage returns the next text or binary message, reassembling
+// fragments. Pings are answered and pongs skipped along the way. When the
+// peer closes, the close is echoed and a *CloseError is returned; protocol
+// violations close the connection with the matching status code.
+func (c *Conn) ReadMessage() (Opcode, []byte, error) {
+	for {
+		f, err := c.ReadFrame()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch f.Opcode {
+		case PingMessage:
+			if err := c.WriteFrame(Frame{Fin: true, Opcode: PongMessage, Payload: f.Payload}); err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(f.Payload)
+		}
+
+		if f.Opcode == ContinuationFrame {
+			if c.receiving == ContinuationFrame {
+				return 0, nil, c.fail(fmt.Errorf("%w: unexpected continuation frame", ErrProtocol))
+			}
+		} else {
+			if c.receiving != ContinuationFrame {
+				return 0, nil, c.fail(fmt.Errorf("%w: interleaved data frame", ErrProtocol))
+			}
+			c.receiving = f.Opcode
+			c.message = c.message[:0]
+		}
+
+		if c.MaxMessageSize > 0 && int64(len(c.message)+len(f.Payload)) > c.MaxMessageSize {
+			return 0, nil, c.fail(ErrMessageTooLarge)
+		}
+		c.message = append(c.message, f.Payload...)
+
+		if !f.Fin {
+			continue
+		}
+
+		op := c.receiving
+		c.receiving = ContinuationFrame
+
+		if op == TextMessage && !utf8.Valid(c.message) {
+			return 0, nil, c.fail(ErrInvalidUTF8)
+		}
+
+		message := append([]byte(nil), c.message...)
+		return op, message, nil
+	}
+}
+
+func (c *Conn) WriteMessage(op Opcode, data []byte) error {
+	return c.WriteFrame(Frame{Fin: true, Opcode: op, Payload: data})
+}
+
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteFrame(Frame{Fin: true, Opcode: PingMessage, Payload: data})
+}
+
+// Close starts the close handshake, waits briefly for the peer to answer
+// and closes the connection.
+func (c *Conn) Close(code CloseCode, reason string) error {
+	err := c.sendClose(code, reason)
+	if err != nil {
+		c.conn.Close()
+		if errors.Is(err, ErrClosed) {
+			return nil
+		}
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		f, err := c.ReadFrame()
+		if err != nil || f.Opcode == CloseMessage {
+			break
+		}
+	}
+
+	return c.conn.Close()
+}
+
+func (c *Conn) sendClose(code CloseCode, reason string) error {
+	var payload []byte
+	if code != CloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+
+	if len(payload) > maxControlPayload {
+		return ErrControlTooLarge
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	c.closeSent = true
+
+	return c.writeFrame(Frame{Fin: true, Opcode: CloseMessage, Payload: payload})
+}
+
+func (c *Conn) handleClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+
+	switch {
+	case len(payload) == 1:
+		return c.fail(fmt.Errorf("%w: truncated close code", ErrProtocol))
+	case len(payload) >= 2:
+		closeErr.Code = CloseCode(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(fmt.Errorf("%w: close code %d", ErrProtocol, closeErr.Code))
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(ErrInvalidUTF8)
+		}
+	}
+
+	// echo the status code, or an empty close when none was sent
+	c.sendClose(closeErr.Code, "")
+	c.conn.Close()
+	return closeErr
+}
+
+// fail closes the connection after a read error, telling the peer why when
+// the error is a protocol one.
+func (c *Conn) fail(err error) error {
+	code := CloseAbnormal
+	switch {
+	case errors.Is(err, ErrProtocol), errors.Is(err, ErrControlTooLarge):
+		code = CloseProtocolError
+	case errors.Is(err, ErrInvalidUTF8):
+		code = CloseInvalidPayload
+	case errors.Is(err, ErrMessageTooLarge):
+		code = CloseMessageTooBig
+	}
+
+	if code != CloseAbnormal {
+		c.sendClose(code, "")
+	}
+	c.conn.Close()
+	return err
+}
+
+func validCloseCode(code CloseCode) bool {
+	switch code {
+	case 1004, CloseNoStatus, CloseAbnormal, 1015:
+		return false
+	}
+	return (code >= 1000 && code <= 1014) || (code >= 3000 && code <= 4999)
+}
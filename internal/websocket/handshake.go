@@ -0,0 +1,292 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"httpfromtcp/internal/headers"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// magic GUID from RFC 6455 section 1.3
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	version    = "13"
+
+	handshakeTimeout = 10 * time.Second
+)
+
+var (
+	ErrNotWebSocket       = errors.New("request is not a websocket upgrade")
+	ErrWrongMethod        = errors.New("websocket upgrade must be a GET request")
+	ErrWrongVersion       = errors.New("unsupported websocket version")
+	ErrWrongKey           = errors.New("missing or malformed sec-websocket-key")
+	ErrHandshakeFailed    = errors.New("websocket handshake failed")
+	ErrWrongURL           = errors.New("websocket url must use ws or wss")
+	ErrHandshakeTooLarge  = errors.New("websocket handshake response too large")
+	ErrWrongAcceptKey     = errors.New("wrong sec-websocket-accept")
+	ErrUnexpectedProtocol = errors.New("server picked a subprotocol that wasn't offered")
+)
+
+// AcceptKey computes the sec-websocket-accept value for a client key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// IsUpgrade reports whether r asks to switch to the websocket protocol.
+func IsUpgrade(r *request.Request) bool {
+	return r.Headers.HasToken("connection", "upgrade") && r.Headers.HasToken("upgrade", "websocket")
+}
+
+// Upgrade validates the handshake in r, takes over the connection behind w
+// and answers with 101 Switching Protocols. When the handshake is invalid
+// an error response is written to w and the error is returned. The first
+// of subprotocols the client also offers is selected.
+func Upgrade(w io.Writer, r *request.Request, subprotocols ...string) (*Conn, error) {
+	key, err := checkHandshake(r)
+	if err != nil {
+		status := response.BAD_REQUEST
+		extra := map[string]string{}
+		if errors.Is(err, ErrWrongVersion) {
+			status = response.UPGRADE_REQUIRED
+			extra["sec-websocket-version"] = version
+		}
+		response.WriteErrorHeaders(w, status, extra, err)
+		return nil, err
+	}
+
+	hijacker, ok := w.(server.Hijacker)
+	if !ok {
+		response.WriteError(w, response.SERVER_ERROR, server.ErrNotHijackable)
+		return nil, server.ErrNotHijackable
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	h := headers.NewHeaders()
+	h["upgrade"] = "websocket"
+	h["connection"] = "Upgrade"
+	h["sec-websocket-accept"] = AcceptKey(key)
+
+	protocol := selectProtocol(r.Headers, subprotocols)
+	if protocol != "" {
+		h["sec-websocket-protocol"] = protocol
+	}
+
+	rw := response.NewWriter(conn)
+	if err := rw.WriteStatusLine(response.SWITCHING_PROTOCOLS); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.WriteHeaders(h); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := newConn(conn, buffered, false)
+	c.Subprotocol = protocol
+	return c, nil
+}
+
+func checkHandshake(r *request.Request) (string, error) {
+	if r.RequestLine.Method != "GET" {
+		return "", ErrWrongMethod
+	}
+
+	if r.RequestLine.HttpVersion != "1.1" || !IsUpgrade(r) {
+		return "", ErrNotWebSocket
+	}
+
+	if v, _ := r.Headers.Get("sec-websocket-version"); strings.TrimSpace(v) != version {
+		return "", ErrWrongVersion
+	}
+
+	key, _ := r.Headers.Get("sec-websocket-key")
+	key = strings.TrimSpace(key)
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != 16 {
+		return "", ErrWrongKey
+	}
+
+	return key, nil
+}
+
+func selectProtocol(h headers.Headers, supported []string) string {
+	for _, p := range supported {
+		if h.HasToken("sec-websocket-protocol", p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// Dial opens a client connection to a ws:// or wss:// url.
+func Dial(rawURL string, subprotocols ...string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var scheme string
+	switch u.Scheme {
+	case "ws":
+		scheme = "http"
+	case "wss":
+		scheme = "https"
+	default:
+		return nil, ErrWrongURL
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if scheme == "https" {
+			port = "443"
+		}
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{
+		Timeout: handshakeTimeout,
+	}
+
+	var conn net.Conn
+	if scheme == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+			ServerName: u.Hostname(),
+		})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := clientHandshake(conn, u, subprotocols)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func clientHandshake(conn net.Conn, u *url.URL, subprotocols []string) (*Conn, error) {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	target := u.RequestURI()
+
+	req := &request.Request{
+		RequestLine: request.RequestLine{
+			Method:        "GET",
+			RequestTarget: target,
+			HttpVersion:   "1.1",
+		},
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
+	}
+	req.Headers["host"] = u.Host
+	req.Headers["upgrade"] = "websocket"
+	req.Headers["connection"] = "Upgrade"
+	req.Headers["sec-websocket-key"] = key
+	req.Headers["sec-websocket-version"] = version
+	if len(subprotocols) > 0 {
+		req.Headers["sec-websocket-protocol"] = strings.Join(subprotocols, ", ")
+	}
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	res, buffered, err := readHandshakeResponse(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusLine.StatusCode != response.SWITCHING_PROTOCOLS {
+		return nil, fmt.Errorf("%w: status %d", ErrHandshakeFailed, res.StatusLine.StatusCode)
+	}
+
+	if !res.Headers.HasToken("upgrade", "websocket") || !res.Headers.HasToken("connection", "upgrade") {
+		return nil, ErrHandshakeFailed
+	}
+
+	if accept, _ := res.Headers.Get("sec-websocket-accept"); strings.TrimSpace(accept) != AcceptKey(key) {
+		return nil, ErrWrongAcceptKey
+	}
+
+	protocol, _ := res.Headers.Get("sec-websocket-protocol")
+	protocol = strings.TrimSpace(protocol)
+	if protocol != "" && !slices.Contains(subprotocols, protocol) {
+		return nil, ErrUnexpectedProtocol
+	}
+
+	c := newConn(conn, buffered, true)
+	c.Subprotocol = protocol
+	return c, nil
+}
+
+// readHandshakeResponse parses the response head and returns whatever was
+// read past it, which already belongs to the websocket stream.
+func readHandshakeResponse(conn net.Conn) (*response.Response, []byte, error) {
+	p := response.NewParser("GET")
+	res := &response.Response{
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
+	}
+
+	buf := make([]byte, 4096)
+	start, end := 0, 0
+
+	for {
+		n, err := p.Feed(buf[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		start += n
+
+		for ev, ok := p.Next(); ok; ev, ok = p.Next() {
+			res.Apply(ev)
+		}
+
+		if p.Done() {
+			return res, append([]byte(nil), buf[start:end]...), nil
+		}
+
+		if end == len(buf) {
+			if start == 0 {
+				return nil, nil, ErrHandshakeTooLarge
+			}
+			end = copy(buf, buf[start:end])
+			start = 0
+		}
+
+		n, err = conn.Read(buf[end:])
+		end += n
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil, ErrHandshakeFailed
+			}
+			return nil, nil, err
+		}
+	}
+}
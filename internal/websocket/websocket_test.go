@@ -0,0 +1,263 @@
+package websocket
+
+import (
+	"bufio"
+	"httpfromtcp/internal/request"
+	"httpfromtcp/internal/response"
+	"httpfromtcp/internal/server"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// example from RFC 6455 section 1.3
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func serveEcho(t *testing.T) (string, <-chan error) {
+	t.Helper()
+	closed := make(chan error, 1)
+
+	s, err := server.Serv(0, func(w io.Writer, r *request.Request) {
+		c, err := Upgrade(w, r, "chat")
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for {
+				op, msg, err := c.ReadMessage()
+				if err != nil {
+					closed <- err
+					return
+				}
+				if err := c.WriteMessage(op, msg); err != nil {
+					closed <- err
+					return
+				}
+			}
+		}()
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return "ws://" + s.Addr().String() + "/ws", closed
+}
+
+func TestEcho(t *testing.T) {
+	url, closed := serveEcho(t)
+
+	c, err := Dial(url, "superchat", "chat")
+	require.NoError(t, err)
+	assert.Equal(t, "chat", c.Subprotocol)
+
+	require.NoError(t, c.WriteMessage(TextMessage, []byte("hello")))
+	op, msg, err := c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, TextMessage, op)
+	assert.Equal(t, "hello", string(msg))
+
+	// a fragmented message with a ping in between
+	require.NoError(t, c.WriteFrame(Frame{Fin: false, Opcode: BinaryMessage, Payload: []byte("frag")}))
+	require.NoError(t, c.Ping([]byte("are you there")))
+	require.NoError(t, c.WriteFrame(Frame{Fin: true, Opcode: ContinuationFrame, Payload: []byte("mented")}))
+
+	f, err := c.ReadFrame()
+	require.NoError(t, err)
+	assert.Equal(t, PongMessage, f.Opcode)
+	assert.Equal(t, "are you there", string(f.Payload))
+
+	op, msg, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, BinaryMessage, op)
+	assert.Equal(t, "fragmented", string(msg))
+
+	big := strings.Repeat("x", 70000)
+	require.NoError(t, c.WriteMessage(TextMessage, []byte(big)))
+	_, msg, err = c.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, big, string(msg))
+
+	require.NoError(t, c.Close(CloseNormal, "bye"))
+
+	err = <-closed
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseNormal, closeErr.Code)
+	assert.Equal(t, "bye", closeErr.Reason)
+}
+
+func TestUpgradeRejected(t *testing.T) {
+	url, _ := serveEcho(t)
+	addr := strings.TrimSuffix(strings.TrimPrefix(url, "ws://"), "/ws")
+
+	tests := []struct {
+		name    string
+		request string
+		status  response.StatusCode
+		version string
+	}{
+		{
+			name:    "Not an upgrade",
+			request: "GET /ws HTTP/1.1\r\nHost: x\r\n\r\n",
+			status:  response.BAD_REQUEST,
+		},
+		{
+			name:    "Wrong method",
+			request: "POST /ws HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n",
+			status:  response.BAD_REQUEST,
+		},
+		{
+			name:    "Short key",
+			request: "GET /ws HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\nSec-WebSocket-Key: c2hvcnQ=\r\nSec-WebSocket-Version: 13\r\n\r\n",
+			status:  response.BAD_REQUEST,
+		},
+		{
+			name:    "Old version",
+			request: "GET /ws HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 8\r\n\r\n",
+			status:  response.UPGRADE_REQUIRED,
+			version: "13",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte(tc.request))
+			require.NoError(t, err)
+
+			res, err := response.ResponseFromReader(conn)
+			require.NoError(t, err)
+			assert.Equal(t, tc.status, res.StatusLine.StatusCode)
+			assert.Equal(t, tc.version, res.Headers["sec-websocket-version"])
+		})
+	}
+}
+
+// clientFrame builds a masked frame with a short payload.
+func clientFrame(b0 byte, payload string) []byte {
+	key := [4]byte{1, 2, 3, 4}
+	data := []byte(payload)
+	mask(key, data)
+
+	frame := []byte{b0, 0x80 | byte(len(data))}
+	frame = append(frame, key[:]...)
+	return append(frame, data...)
+}
+
+// readRaw feeds raw bytes to the server side of a connection and returns
+// everything the server wrote back together with the ReadMessage error.
+func readRaw(t *testing.T, raw []byte, maxMessageSize int64) ([]byte, error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	serverConn, err := l.Accept()
+	require.NoError(t, err)
+
+	c := newConn(serverConn, nil, false)
+	c.MaxMessageSize = maxMessageSize
+
+	_, err = client.Write(raw)
+	require.NoError(t, err)
+
+	_, _, readErr := c.ReadMessage()
+
+	reply, _ := io.ReadAll(bufio.NewReader(client))
+	return reply, readErr
+}
+
+func TestReadMessageViolations(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   []byte
+		err   error
+		reply []byte
+	}{
+		{
+			name:  "Unmasked client frame",
+			raw:   []byte{0x81, 0x02, 'h', 'i'},
+			err:   ErrProtocol,
+			reply: []byte{0x88, 0x02, 0x03, 0xEA},
+		},
+		{
+			name:  "Reserved bits",
+			raw:   clientFrame(0xC1, "hi"),
+			err:   ErrProtocol,
+			reply: []byte{0x88, 0x02, 0x03, 0xEA},
+		},
+		{
+			name:  "Unknown opcode",
+			raw:   clientFrame(0x83, "hi"),
+			err:   ErrProtocol,
+			reply: []byte{0x88, 0x02, 0x03, 0xEA},
+		},
+		{
+			name:  "Continuation without start",
+			raw:   clientFrame(0x80, "hi"),
+			err:   ErrProtocol,
+			reply: []byte{0x88, 0x02, 0x03, 0xEA},
+		},
+		{
+			name:  "Fragmented ping",
+			raw:   clientFrame(0x09, "hi"),
+			err:   ErrProtocol,
+			reply: []byte{0x88, 0x02, 0x03, 0xEA},
+		},
+		{
+			name:  "Invalid utf-8",
+			raw:   clientFrame(0x81, "\xff\xfe"),
+			err:   ErrInvalidUTF8,
+			reply: []byte{0x88, 0x02, 0x03, 0xEF},
+		},
+		{
+			name:  "Frame over limit",
+			raw:   clientFrame(0x82, "0123456789"),
+			err:   ErrMessageTooLarge,
+			reply: []byte{0x88, 0x02, 0x03, 0xF1},
+		},
+		{
+			name:  "Fragments over limit",
+			raw:   append(clientFrame(0x02, "01234"), clientFrame(0x80, "56789")...),
+			err:   ErrMessageTooLarge,
+			reply: []byte{0x88, 0x02, 0x03, 0xF1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reply, err := readRaw(t, tc.raw, 8)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.reply, reply)
+		})
+	}
+}
+
+func TestReadMessageAnswersPingAndClose(t *testing.T) {
+	raw := clientFrame(0x89, "p")
+	raw = append(raw, clientFrame(0x88, "\x03\xe9gone")...)
+
+	reply, err := readRaw(t, raw, 0)
+
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseGoingAway, closeErr.Code)
+	assert.Equal(t, "gone", closeErr.Reason)
+
+	expected := []byte{0x8A, 0x01, 'p', 0x88, 0x02, 0x03, 0xE9}
+	assert.Equal(t, expected, reply)
+}